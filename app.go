@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"encoding/base64"
@@ -14,14 +18,48 @@ import (
 
 // App struct
 type App struct {
-	ctx          context.Context
-	sshClient    *ssh.Client // Aktif SSH bağlantısını tutar
-	nodePassword string      // Add this field
+	ctx context.Context
+
+	mu      sync.RWMutex
+	servers map[string]*serverConn // Aktif SSH bağlantıları, server ID ile
+
+	hostKeys   *hostKeyStore      // Trusted server host keys (known_hosts)
+	prompts    *promptRegistry    // Prompts waiting for an answer from the frontend
+	profiles   *profileStore      // Saved server profiles, secrets encrypted at rest
+	logStreams *logStreamRegistry // Active node log subscriptions
+	tunnels    *tunnelRegistry    // Local port forwards through server connections
+	checksums  *checksumStore     // Release archive hashes pinned by the user
+	secrets    *secretGuard       // Passwords and keys that must never reach a command line
+
+	stakingMonitors *stakingMonitorRegistry // Background missed-block monitors
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{
+		servers:    make(map[string]*serverConn),
+		hostKeys:   newHostKeyStore(filepath.Join(appConfigDir(), "known_hosts")),
+		prompts:    newPromptRegistry(),
+		profiles:   newProfileStore(filepath.Join(appConfigDir(), "profiles.json")),
+		logStreams: newLogStreamRegistry(),
+		tunnels:    newTunnelRegistry(),
+		checksums:  newChecksumStore(filepath.Join(appConfigDir(), "release-checksums.json")),
+		secrets:    newSecretGuard(),
+
+		stakingMonitors: newStakingMonitorRegistry(),
+	}
+}
+
+// appConfigDir returns the directory where the manager keeps its local state
+// (known hosts, settings, ...). It falls back to the working directory if the
+// OS does not report a user config directory.
+func appConfigDir() string {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		fmt.Printf("Could not determine user config dir, using working directory: %v\n", err)
+		base = "."
+	}
+	return filepath.Join(base, "massa-node-manager")
 }
 
 // Startup is called when the app starts. The context is saved
@@ -41,22 +79,20 @@ func (a *App) DomReady(ctx context.Context) {
 // Returning true will prevent the application from quitting.
 func (a *App) BeforeClose(ctx context.Context) (prevent bool) {
 	fmt.Println("App BeforeClose called")
-	// Ensure SSH client is closed if user tries to close window while connected
-	if a.sshClient != nil {
-		fmt.Println("SSH client connected, attempting to close it before quitting app...")
-		a.sshClient.Close()
-		a.sshClient = nil // Set to nil after closing
-		fmt.Println("SSH client closed during BeforeClose.")
+	// Ensure SSH clients are closed if user tries to close window while connected
+	if len(a.serverIDs()) > 0 {
+		fmt.Println("SSH clients connected, attempting to close them before quitting app...")
+		a.closeAllServers()
+		fmt.Println("SSH clients closed during BeforeClose.")
 	}
 	return false // Default to allow closing
 }
 
 // OnShutdown is called when the app is about to quit.
 func (a *App) OnShutdown(ctx context.Context) {
-	if a.sshClient != nil {
-		a.sshClient.Close()
-		a.sshClient = nil // Explicitly set to nil
-		fmt.Println("SSH client closed on shutdown.")
+	if len(a.serverIDs()) > 0 {
+		a.closeAllServers()
+		fmt.Println("SSH clients closed on shutdown.")
 	}
 	fmt.Println("App OnShutdown called")
 }
@@ -66,57 +102,93 @@ func (a *App) Greet(name string) string {
 	return fmt.Sprintf("Hello %s, It's show time!", name)
 }
 
-// ConnectToServer establishes an SSH connection to the server.
-func (a *App) ConnectToServer(host string, port int, user string, password string) (string, error) {
-	if a.sshClient != nil {
-		// Mevcut bir bağlantı varsa kapat
-		err := a.sshClient.Close()
-		if err != nil {
-			// Hata olması durumunda loglayalım ama devam edelim
-			fmt.Printf("Error closing existing SSH connection: %v\n", err)
-		}
-		a.sshClient = nil
-		a.nodePassword = "" // Reset node password on disconnect
-		fmt.Println("Previous SSH connection closed.")
-	}
-
-	fmt.Printf("Attempting to connect to %s:%d as %s\n", host, port, user)
+// ConnectToServer establishes an SSH connection to the server using a password and
+// registers it under serverID. Servers that require keyboard-interactive auth
+// (e.g. 2FA) are also supported.
+func (a *App) ConnectToServer(serverID string, host string, port int, user string, password string) (string, error) {
+	return a.ConnectToServerWithAuth(serverID, host, port, user, AuthOptions{
+		Methods:  []string{authMethodPassword, authMethodKeyboardInteractive},
+		Password: password,
+	})
+}
 
-	sshConfig := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // WARNING: Insecure, use for development only. In production, you should verify the host key.
-		Timeout:         10 * time.Second,            // Bağlantı zaman aşımını artırdık
+// ConnectToServerWithAuth establishes an SSH connection to the server, trying the
+// authentication methods in auth in order (private key, ssh-agent, password,
+// keyboard-interactive). Connections to other servers are left untouched; an
+// existing connection with the same serverID is replaced, keeping its node
+// password, service mode, Massa version and layout.
+func (a *App) ConnectToServerWithAuth(serverID string, host string, port int, user string, auth AuthOptions) (string, error) {
+	if serverID == "" {
+		return "Error: Server ID is required.", fmt.Errorf("server ID is required")
 	}
 
+	fmt.Printf("Attempting to connect to %s:%d as %s (server %s)\n", host, port, user, serverID)
+
 	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+	client, err := a.dialSSH(host, port, user, auth, true)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to dial: %s", err) // Simplified error for frontend
 		fmt.Printf("Connection error for %s: %v\n", addr, err)
 		return errMsg, err
 	}
 
-	a.sshClient = client
+	srv := newServerConn(serverID, host, port, user, auth, client)
+	previous := a.addServer(srv)
+	go a.superviseServer(srv)
+	if previous != nil {
+		// Mevcut bir bağlantı varsa kapat
+		if err := previous.close(); err != nil {
+			// Hata olması durumunda loglayalım ama devam edelim
+			fmt.Printf("Error closing previous SSH connection for %s: %v\n", serverID, err)
+		}
+		fmt.Printf("Previous SSH connection for %s closed.\n", serverID)
+	}
+
 	successMsg := fmt.Sprintf("Successfully connected to %s!", addr)
 	fmt.Println(successMsg)
 	return successMsg, nil
 }
 
-// DisconnectFromServer closes the active SSH connection.
-func (a *App) DisconnectFromServer() (string, error) {
-	fmt.Println("Attempting to disconnect from server...")
-	if a.sshClient == nil {
+// dialSSH opens a new SSH client. It is used for the initial connection and by
+// the supervisor when reconnecting. Unless interactive is set, nothing is asked
+// of the user: unknown host keys and keyboard-interactive questions other than
+// the password make the dial fail.
+func (a *App) dialSSH(host string, port int, user string, auth AuthOptions, interactive bool) (*ssh.Client, error) {
+	authMethods, cleanupAuth, err := a.buildAuthMethods(user, auth, interactive)
+	if err != nil {
+		return nil, fmt.Errorf("authentication setup failed: %w", err)
+	}
+	defer cleanupAuth()
+
+	sshConfig := &ssh.ClientConfig{
+		User:            user,
+		Auth:            authMethods,
+		HostKeyCallback: a.verifyHostKey,  // Trust on first use, reject changed keys
+		Timeout:         10 * time.Second, // Bağlantı zaman aşımını artırdık
+	}
+	if !interactive {
+		sshConfig.HostKeyCallback = a.verifyKnownHostKey
+	}
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	sshConfig.HostKeyAlgorithms = a.hostKeys.algorithms(addr)
+	return ssh.Dial("tcp", addr, sshConfig)
+}
+
+// DisconnectFromServer closes the SSH connection registered under serverID.
+func (a *App) DisconnectFromServer(serverID string) (string, error) {
+	fmt.Printf("Attempting to disconnect from server %s...\n", serverID)
+	a.logStreams.stopServer(serverID)
+	a.tunnels.closeServer(serverID)
+	a.stakingMonitors.stopServer(serverID)
+	srv := a.removeServer(serverID)
+	if srv == nil {
 		errMsg := "No active SSH connection to disconnect."
 		fmt.Println(errMsg)
 		return errMsg, nil // Not an error per se, but no action taken
 	}
 
-	err := a.sshClient.Close()
-	a.sshClient = nil   // Set to nil regardless of close error
-	a.nodePassword = "" // Reset node password
+	err := srv.close() // Node password goes away with the connection
 	if err != nil {
 		errMsg := fmt.Sprintf("Error while disconnecting: %v", err)
 		fmt.Println(errMsg)
@@ -128,28 +200,48 @@ func (a *App) DisconnectFromServer() (string, error) {
 	return successMsg, nil
 }
 
-// RunCommand executes a command on the connected SSH server.
-func (a *App) RunCommand(command string) (string, error) {
-	if a.sshClient == nil {
+// RunCommand executes a command on the server registered under serverID.
+func (a *App) RunCommand(serverID string, command string) (string, error) {
+	return a.runCommand(serverID, command, nil)
+}
+
+// runCommand is RunCommand with optional data fed to the command's stdin, which
+// is how secrets are handed to remote commands without putting them in argv.
+func (a *App) runCommand(serverID string, command string, stdin io.Reader) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
 		errMsg := "Error: No active SSH connection."
 		fmt.Println(errMsg)
-		return errMsg, fmt.Errorf(errMsg)
+		return errMsg, err
+	}
+
+	if err := a.checkCommandLine(srv, command); err != nil {
+		errMsg := "Error: refusing to put a secret on a remote command line."
+		fmt.Println(errMsg)
+		return errMsg, err
 	}
 
-	fmt.Printf("Running command: %s\n", command)
+	fmt.Printf("Running command on %s: %s\n", serverID, command)
 
-	session, err := a.sshClient.NewSession()
+	client, err := srv.sshClient()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to create session: %v", err)
+		errMsg := fmt.Sprintf("Error: %v", err)
 		fmt.Println(errMsg)
 		return errMsg, err
 	}
+	session, err := client.NewSession()
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to create session: %v", err)
+		fmt.Println(errMsg)
+		return errMsg, fmt.Errorf("%w: %v", errConnectionLost, err)
+	}
 	defer session.Close()
 
 	var stdoutBuf bytes.Buffer
 	var stderrBuf bytes.Buffer
 	session.Stdout = &stdoutBuf
 	session.Stderr = &stderrBuf
+	session.Stdin = stdin
 
 	err = session.Run(command)
 	stdoutStr := stdoutBuf.String()
@@ -169,24 +261,101 @@ func (a *App) RunCommand(command string) (string, error) {
 	if err != nil {
 		// Log the detailed error for backend debugging.
 		// The 'combinedOutput' will be returned to the frontend, which includes stdout and stderr.
-		fmt.Printf("Error running command '%s': %v\nStdout:\n%s\nStderr:\n%s\n", command, err, stdoutStr, stderrStr)
+		fmt.Printf("Error running command '%s': %v\nStdout:\n%s\nStderr:\n%s\n", command, err, a.redactOutput(srv, stdoutStr), a.redactOutput(srv, stderrStr))
+		if isConnectionError(err) {
+			err = fmt.Errorf("%w: %v", errConnectionLost, err)
+		}
 		// Return the error so the calling Go function knows the command failed.
 		return strings.TrimSpace(combinedOutput), err
 	}
 
-	fmt.Printf("Command '%s' executed. Combined output:\n%s\n", command, combinedOutput)
+	fmt.Printf("Command '%s' executed. Combined output:\n%s\n", command, a.redactOutput(srv, combinedOutput))
 	return strings.TrimSpace(combinedOutput), nil
 }
 
+// checkCommandLine refuses commands that carry a secret. Secrets reach the
+// server over stdin or in private files only; anything on the command line is
+// visible in the process list and shell history. The node password is matched
+// as a whole word, so a password that also occurs inside paths or screen names
+// does not block those commands.
+func (a *App) checkCommandLine(srv *serverConn, command string) error {
+	if pw := srv.getNodePassword(); a.secrets.contains(command) || (pw != "" && commandHasWord(command, pw)) {
+		return fmt.Errorf("refusing to put a secret on a remote command line")
+	}
+	return nil
+}
+
+// runCommandStream is runCommand for commands whose output must not be logged
+// or altered, such as file contents: stdout is copied to stdout as is, and
+// stderr only ends up in the error.
+func (a *App) runCommandStream(serverID string, command string, stdin io.Reader, stdout io.Writer) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkCommandLine(srv, command); err != nil {
+		return err
+	}
+
+	fmt.Printf("Running command on %s (output not logged): %s\n", serverID, command)
+
+	client, err := srv.sshClient()
+	if err != nil {
+		return err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errConnectionLost, err)
+	}
+	defer session.Close()
+
+	var stderrBuf bytes.Buffer
+	session.Stdout = stdout
+	session.Stderr = &stderrBuf
+	session.Stdin = stdin
+	if err := session.Run(command); err != nil {
+		if isConnectionError(err) {
+			err = fmt.Errorf("%w: %v", errConnectionLost, err)
+		}
+		if msg := strings.TrimSpace(a.redactOutput(srv, stderrBuf.String())); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// redactOutput hides registered secrets and srv's node password in command
+// output before it is logged.
+func (a *App) redactOutput(srv *serverConn, output string) string {
+	output = a.secrets.redact(output)
+	if pw := srv.getNodePassword(); len(pw) >= minGuardedSecretLen {
+		output = strings.ReplaceAll(output, pw, "[REDACTED]")
+	}
+	return output
+}
+
 // SetupAndRunMassaComponents creates and executes a script to install/setup and run Massa node and client.
-func (a *App) SetupAndRunMassaComponents(nodePassword string, publicIp string, forceReinstall bool) (string, error) {
+// An empty publicIp uses the address suggested by DetectPublicIPs.
+func (a *App) SetupAndRunMassaComponents(serverID string, nodePassword string, publicIp string, forceReinstall bool) (string, error) {
 	fmt.Printf("SetupAndRunMassaComponents called. Node Password: [REDACTED], Public IP: %s, Force Reinstall: %t\\n", publicIp, forceReinstall)
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
+	return a.setupMassaComponents(serverID, nodePassword, publicIp, forceReinstall, nil)
+}
+
+// setupMassaComponents runs the setup. With upload set, the release comes from
+// an archive already uploaded to the server instead of GitHub.
+func (a *App) setupMassaComponents(serverID string, nodePassword string, publicIp string, forceReinstall bool, upload *uploadedRelease) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+
+	if err := validNodePassword(nodePassword); err != nil {
+		return fmt.Sprintf("Error: %v", err), err
 	}
 
 	// Save the node password for future use with massa-client
-	a.nodePassword = nodePassword
+	srv.setNodePassword(nodePassword)
 
 	// Sanitize publicIp for local testing scenarios
 	actualPublicIp := publicIp
@@ -194,36 +363,93 @@ func (a *App) SetupAndRunMassaComponents(nodePassword string, publicIp string, f
 		actualPublicIp = "127.0.0.1"
 		fmt.Println("Warning: 'localhost' provided as Public IP. Using '127.0.0.1' for config.toml. For a real routable node, provide a public IP.")
 	}
+	if strings.TrimSpace(publicIp) == "" {
+		detected, err := a.DetectPublicIPs(serverID)
+		if err != nil {
+			return "Error: Could not detect the server's public IP.", err
+		}
+		if detected.Suggested == "" {
+			return "Error: No public IP found on the server; please enter one.", fmt.Errorf("no public IP detected on server %s", serverID)
+		}
+		actualPublicIp = detected.Suggested
+		fmt.Printf("Using detected public IP %s\n", actualPublicIp)
+	}
+	routableIP, ipWarnings, err := parseRoutableIP(actualPublicIp)
+	if err != nil {
+		return fmt.Sprintf("Error: %v.", err), fmt.Errorf("invalid public IP: %w", err)
+	}
+	actualPublicIp = routableIP.String()
+	for _, w := range ipWarnings {
+		fmt.Println("Warning: " + w)
+	}
 
 	scriptContent := fmt.Sprintf(`#!/bin/bash
 set -e
 # set -o pipefail # pipefail can sometimes hide errors from commands in a pipeline if not handled carefully
 
-NODE_PASSWORD="$1"
+# Private directory with node_password and client_password files. The password
+# itself is never passed on a command line; massa-run.sh types it into the prompt.
+NODE_PASSWORD_DIR="$1"
+# Each runner deletes its password file once read; on exit, remove the files
+# no runner was started with.
+NODE_PASSWORD_HANDED=""
+CLIENT_PASSWORD_HANDED=""
+remove_unused_passwords() {
+    [ -n "${NODE_PASSWORD_HANDED}" ] || rm -f "${NODE_PASSWORD_DIR}/node_password"
+    [ -n "${CLIENT_PASSWORD_HANDED}" ] || rm -f "${NODE_PASSWORD_DIR}/client_password"
+    rmdir "${NODE_PASSWORD_DIR}" 2>/dev/null || true
+}
+trap remove_unused_passwords EXIT
 PUBLIC_IP_FROM_ARG="$2"
 # Third argument to script will be forceReinstall flag
 FORCE_REINSTALL_FLAG="$3"
+# Fourth argument selects how the node is run: "screen" (default) or "systemd"
+SERVICE_MODE="${4:-screen}"
+# Fifth argument is the Massa release to install
+MASSA_VERSION="${5:-%s}"
+# Sixth argument is the expected SHA-256 of the release archive. A download is
+# refused without it.
+EXPECTED_SHA256="${6:-}"
+# Seventh to ninth arguments: install base directory, the account that runs the
+# node and client (empty for the current user) and the node's storage directory
+# (empty to keep it inside the install directory).
+INSTALL_BASE_DIR="${7:-%s}"
+RUN_AS_USER="${8:-}"
+DATA_DIR="${9:-}"
+# Tenth argument: a release archive uploaded by the manager, used instead of
+# downloading one. Its SHA-256 is still checked against the sixth argument.
+LOCAL_ARCHIVE="${10:-}"
+# Eleventh argument: set when the uploaded archive could not be checked against
+# a published or user-supplied checksum. The sixth argument then only proves
+# the upload arrived intact.
+UNVERIFIED="${11:-}"
+# Twelfth argument: the release archive built for this server's architecture.
+MASSA_ARCHIVE="${12:-massa_${MASSA_VERSION}_release_linux.tar.gz}"
 
-INSTALL_BASE_DIR="/root/massa_node"
 MASSA_INSTALL_DIR="${INSTALL_BASE_DIR}/massa"
-MASSA_VERSION="MAIN.2.4"
 EXPECTED_NODE_DIR="${MASSA_INSTALL_DIR}/massa-node"
 EXPECTED_CLIENT_DIR="${MASSA_INSTALL_DIR}/massa-client"
 NODE_SCREEN_NAME="massa_node"
 CLIENT_SCREEN_NAME="massa_client"
 NODE_LOG_PATH="${EXPECTED_NODE_DIR}/logs.txt"
+MASSA_RUNNER="${INSTALL_BASE_DIR}/massa-run.sh"
+SYSTEMD_UNIT_NAME="massa-node.service"
 
-CONFIG_IP="%s" # This will be actualPublicIp from Go
+CONFIG_IP=%s # This will be actualPublicIp from Go
 
 echo "--- Massa Node and Client Setup Script ---"
 echo "Node Password: [REDACTED]"
 echo "Public IP Argument: ${PUBLIC_IP_FROM_ARG}"
 echo "IP for config.toml: ${CONFIG_IP}"
 echo "Installation Base Directory: ${INSTALL_BASE_DIR}"
+echo "Run As User: ${RUN_AS_USER:-$(id -un)}"
+echo "Data Directory: ${DATA_DIR:-${EXPECTED_NODE_DIR}/storage}"
 echo "Force Reinstall Flag: ${FORCE_REINSTALL_FLAG}"
+echo "Service Mode: ${SERVICE_MODE}"
+echo "Massa Version: ${MASSA_VERSION}"
 echo "------------------------------------------"
 
-if [ -z "${NODE_PASSWORD}" ]; then
+if [ ! -f "${NODE_PASSWORD_DIR}/node_password" ]; then
     echo "ERROR: Node password is not set. Exiting."
     exit 1
 fi
@@ -233,6 +459,20 @@ if [ -z "${CONFIG_IP}" ]; then
     exit 1
 fi
 
+# Runs a command as the node's user when one is configured.
+as_node_user() {
+    if [ -n "${RUN_AS_USER}" ] && [ "${RUN_AS_USER}" != "$(id -un)" ]; then
+        runuser -u "${RUN_AS_USER}" -- "$@"
+    else
+        "$@"
+    fi
+}
+
+if [ -n "${RUN_AS_USER}" ] && ! id -u "${RUN_AS_USER}" > /dev/null 2>&1; then
+    echo "Creating system user ${RUN_AS_USER}..."
+    useradd --system --create-home --shell /bin/bash "${RUN_AS_USER}"
+fi
+
 # Handle forceful reinstallation if flag is set
 if [ "${FORCE_REINSTALL_FLAG}" = "true" ]; then
     echo "INFO: Force reinstall flag is set to true."
@@ -253,10 +493,21 @@ fi
 if [ -d "${EXPECTED_NODE_DIR}" ] && [ -f "${EXPECTED_NODE_DIR}/massa-node" ] && [ -d "${EXPECTED_CLIENT_DIR}" ] && [ -f "${EXPECTED_CLIENT_DIR}/massa-client" ]; then
     echo "INFO: Existing complete Massa node and client installation detected at ${INSTALL_BASE_DIR}/massa."
     echo "Proceeding to ensure services are started/restarted."
+    if [ -n "${LOCAL_ARCHIVE}" ]; then
+        echo "INFO: Uploaded archive not used since Massa is already installed. Use force reinstall to install it."
+        rm -f "${LOCAL_ARCHIVE}"
+    fi
 else
     echo "INFO: No existing complete Massa node and client installation found. Proceeding with download and setup."
 
     if [ -d "${INSTALL_BASE_DIR}/massa" ]; then
+        # Only a force reinstall, which backs the wallet up first, may delete it.
+        for WALLET_FILE in %[4]s; do
+            if [ -n "$(find "${INSTALL_BASE_DIR}/massa/${WALLET_FILE}" -type f 2>/dev/null | head -n 1)" ]; then
+                echo "ERROR: The incomplete installation at '${INSTALL_BASE_DIR}/massa' still holds wallet files (${WALLET_FILE}). Not deleting it; use force reinstall, which backs the wallet up first. Exiting."
+                exit 1
+            fi
+        done
         echo "WARN: Found existing '${INSTALL_BASE_DIR}/massa' directory but the setup seems incomplete. Cleaning it up..."
         rm -rf "${INSTALL_BASE_DIR}/massa"
         if [ $? -ne 0 ]; then echo "ERROR: Failed to clean up existing incomplete '${INSTALL_BASE_DIR}/massa' directory. Exiting."; exit 1; fi
@@ -266,18 +517,40 @@ else
     if [ $? -ne 0 ]; then echo "ERROR: Failed to create base directory ${INSTALL_BASE_DIR}. Exiting."; exit 1; fi
     echo "Created base directory: ${INSTALL_BASE_DIR}"
 
-    DOWNLOAD_URL="https://github.com/massalabs/massa/releases/download/${MASSA_VERSION}/massa_${MASSA_VERSION}_release_linux.tar.gz"
+    DOWNLOAD_URL="https://github.com/massalabs/massa/releases/download/${MASSA_VERSION}/${MASSA_ARCHIVE}"
     ARCHIVE_NAME="massa_release.tar.gz"
 
-    echo "Downloading Massa ${MASSA_VERSION} from ${DOWNLOAD_URL} (output suppressed)..."
     cd "${INSTALL_BASE_DIR}"
-    # Suppress wget output completely
-    if ! wget -O "${ARCHIVE_NAME}" "${DOWNLOAD_URL}" > /dev/null 2>&1; then
-        echo "ERROR: Failed to download Massa archive. Check URL or network. Exiting."
-        rm -f "${ARCHIVE_NAME}" # Clean up partial download
+    if [ -n "${LOCAL_ARCHIVE}" ]; then
+        echo "Using uploaded archive ${LOCAL_ARCHIVE} instead of downloading."
+        mv "${LOCAL_ARCHIVE}" "${ARCHIVE_NAME}"
+    else
+        echo "Downloading Massa ${MASSA_VERSION} from ${DOWNLOAD_URL} (output suppressed)..."
+        # Suppress wget output completely
+        if ! wget -O "${ARCHIVE_NAME}" "${DOWNLOAD_URL}" > /dev/null 2>&1; then
+            echo "ERROR: Failed to download Massa archive. Check URL or network. Exiting."
+            rm -f "${ARCHIVE_NAME}" # Clean up partial download
+            exit 1
+        fi
+        echo "Download complete."
+    fi
+
+    if [ -z "${EXPECTED_SHA256}" ]; then
+        echo "ERROR: No verified SHA-256 is known for Massa ${MASSA_VERSION}. Refusing to install an unverified archive."
+        rm -f "${ARCHIVE_NAME}"
+        exit 1
+    fi
+    ACTUAL_SHA256=$(sha256sum "${ARCHIVE_NAME}" | cut -d' ' -f1)
+    if [ "${ACTUAL_SHA256}" != "${EXPECTED_SHA256}" ]; then
+        echo "ERROR: SHA-256 mismatch for the Massa ${MASSA_VERSION} archive: expected ${EXPECTED_SHA256}, got ${ACTUAL_SHA256}. The archive was not extracted."
+        rm -f "${ARCHIVE_NAME}"
         exit 1
     fi
-    echo "Download complete."
+    if [ -n "${UNVERIFIED}" ]; then
+        echo "WARNING: SHA-256 ${ACTUAL_SHA256} matches the upload but was not checked against a published checksum."
+    else
+        echo "SHA-256 verified: ${ACTUAL_SHA256}"
+    fi
 
     echo "Extracting archive ${ARCHIVE_NAME} into '${INSTALL_BASE_DIR}' (should create a 'massa' subdirectory)..."
     if ! tar -xzf "${ARCHIVE_NAME}" -C "${INSTALL_BASE_DIR}"; then
@@ -293,6 +566,9 @@ else
     echo "Cleaning up archive ${ARCHIVE_NAME}..."
     rm "${ARCHIVE_NAME}"
     echo "Archive cleaned up."
+    printf '%%s\n' "${MASSA_VERSION}" > "${MASSA_INSTALL_DIR}/VERSION"
+    printf '%%s\n' "${ACTUAL_SHA256}" > "${MASSA_INSTALL_DIR}/VERSION.sha256"
+    printf '%%s %%s %%s%%s\n' "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" "${MASSA_VERSION}" "${ACTUAL_SHA256}" "${UNVERIFIED:+ unverified}" >> "${INSTALL_BASE_DIR}/releases.log"
 
     NODE_CONFIG_DIR="${EXPECTED_NODE_DIR}/config"
     NODE_CONFIG_FILE="${NODE_CONFIG_DIR}/config.toml"
@@ -312,6 +588,25 @@ EOF
     cat "${NODE_CONFIG_FILE}" || echo "WARN: Could not display config file content."
 fi
 
+# The node keeps its ledger and peers under massa-node/storage. With a data
+# directory configured that path becomes a link to it, moving any existing data.
+if [ -n "${DATA_DIR}" ]; then
+    mkdir -p "${DATA_DIR}"
+    if [ -d "${EXPECTED_NODE_DIR}/storage" ] && [ ! -L "${EXPECTED_NODE_DIR}/storage" ]; then
+        echo "Moving existing node storage to ${DATA_DIR}..."
+        cp -a "${EXPECTED_NODE_DIR}/storage/." "${DATA_DIR}/"
+        rm -rf "${EXPECTED_NODE_DIR}/storage"
+    fi
+    ln -sfn "${DATA_DIR}" "${EXPECTED_NODE_DIR}/storage"
+    echo "Node storage is at ${DATA_DIR}."
+fi
+
+if [ -n "${RUN_AS_USER}" ]; then
+    echo "Handing ${INSTALL_BASE_DIR} to ${RUN_AS_USER}..."
+    chown -R "${RUN_AS_USER}:" "${INSTALL_BASE_DIR}"
+    if [ -n "${DATA_DIR}" ]; then chown -R "${RUN_AS_USER}:" "${DATA_DIR}"; fi
+fi
+
 # --- Screen Management ---
 echo ""
 echo "--- Managing Screen Sessions ---"
@@ -320,15 +615,15 @@ terminate_screen() {
     local screen_name="$1"
     echo "Checking for existing screen session: ${screen_name}..."
     # Simpler grep, just check if the name exists in the list, might catch substrings if names are too similar
-    if screen -list | grep -q "${screen_name}"; then 
+    if as_node_user screen -list | grep -q "${screen_name}"; then 
         echo "Found existing screen session ${screen_name}, attempting to terminate it..."
-        screen -S "${screen_name}" -X quit
+        as_node_user screen -S "${screen_name}" -X quit
         sleep 1 
-        if screen -list | grep -q "${screen_name}"; then
+        if as_node_user screen -list | grep -q "${screen_name}"; then
             echo "WARN: Failed to terminate screen ${screen_name} with quit. Trying to kill it..."
-            screen -S "${screen_name}" -X kill
+            as_node_user screen -S "${screen_name}" -X kill
             sleep 1
-            if screen -list | grep -q "${screen_name}"; then
+            if as_node_user screen -list | grep -q "${screen_name}"; then
                  echo "ERROR: Still failed to terminate screen ${screen_name} after kill. Manual check required."
             else
                  echo "Screen ${screen_name} terminated with kill."
@@ -345,48 +640,54 @@ terminate_screen "${NODE_SCREEN_NAME}"
 terminate_screen "${CLIENT_SCREEN_NAME}"
 
 # Start Massa Node
-echo ""
-echo "Starting Massa Node in screen session: ${NODE_SCREEN_NAME}"
-echo "Node logs will be at: ${NODE_LOG_PATH}"
-if [ ! -f "${EXPECTED_NODE_DIR}/massa-node" ]; then
-    echo "ERROR: Massa node executable not found at ${EXPECTED_NODE_DIR}/massa-node. Cannot start node."
-    exit 1
-fi
-chmod +x "${EXPECTED_NODE_DIR}/massa-node"
-
-# Ensure log directory exists and log file is writable, or clear old log
-rm -f "${NODE_LOG_PATH}"
-touch "${NODE_LOG_PATH}"
-
-NODE_START_CMD="cd '${EXPECTED_NODE_DIR}' && ./massa-node -p '${NODE_PASSWORD}' |& tee '${NODE_LOG_PATH}'"
-echo "Executing in screen: screen -dmS ${NODE_SCREEN_NAME} /bin/bash -c \"${NODE_START_CMD}\""
-screen -dmS "${NODE_SCREEN_NAME}" /bin/bash -c "${NODE_START_CMD}"
-NODE_SCREEN_EXIT_CODE=$?
-echo "Screen command for node exited with code: ${NODE_SCREEN_EXIT_CODE}"
-sleep 8 # Increased sleep
-
-echo "Verifying node screen session ${NODE_SCREEN_NAME}..."
-if screen -list | grep -q "${NODE_SCREEN_NAME}"; then
-    echo "INFO: Massa Node screen session ${NODE_SCREEN_NAME} was created."
-    echo "Checking node log file (${NODE_LOG_PATH}) for activity (last 20 lines)..."
-    if [ -f "${NODE_LOG_PATH}" ]; then # Check if log file exists
-        if [ -s "${NODE_LOG_PATH}" ]; then # Check if log file is not empty
-            echo "SUCCESS: Massa Node log file contains data. Last 20 lines:"
-            tail -n 20 "${NODE_LOG_PATH}"
+if [ "${SERVICE_MODE}" = "systemd" ]; then
+    echo ""
+    echo "INFO: Service mode is systemd. The node will be started by the ${SYSTEMD_UNIT_NAME} unit instead of a screen session."
+else
+    echo ""
+    echo "Starting Massa Node in screen session: ${NODE_SCREEN_NAME}"
+    echo "Node logs will be at: ${NODE_LOG_PATH}"
+    if [ ! -f "${EXPECTED_NODE_DIR}/massa-node" ]; then
+        echo "ERROR: Massa node executable not found at ${EXPECTED_NODE_DIR}/massa-node. Cannot start node."
+        exit 1
+    fi
+    chmod +x "${EXPECTED_NODE_DIR}/massa-node"
+
+    # Ensure log directory exists and log file is writable, or clear old log
+    rm -f "${NODE_LOG_PATH}"
+    as_node_user touch "${NODE_LOG_PATH}"
+
+    NODE_START_CMD="cd '${EXPECTED_NODE_DIR}' && '${MASSA_RUNNER}' massa-node '${NODE_PASSWORD_DIR}/node_password' |& tee '${NODE_LOG_PATH}'"
+    echo "Executing in screen: screen -dmS ${NODE_SCREEN_NAME} /bin/bash -c \"${NODE_START_CMD}\""
+    as_node_user screen -dmS "${NODE_SCREEN_NAME}" /bin/bash -c "${NODE_START_CMD}"
+    NODE_SCREEN_EXIT_CODE=$?
+    NODE_PASSWORD_HANDED=1
+    echo "Screen command for node exited with code: ${NODE_SCREEN_EXIT_CODE}"
+    sleep 8 # Increased sleep
+
+    echo "Verifying node screen session ${NODE_SCREEN_NAME}..."
+    if as_node_user screen -list | grep -q "${NODE_SCREEN_NAME}"; then
+        echo "INFO: Massa Node screen session ${NODE_SCREEN_NAME} was created."
+        echo "Checking node log file (${NODE_LOG_PATH}) for activity (last 20 lines)..."
+        if [ -f "${NODE_LOG_PATH}" ]; then # Check if log file exists
+            if [ -s "${NODE_LOG_PATH}" ]; then # Check if log file is not empty
+                echo "SUCCESS: Massa Node log file contains data. Last 20 lines:"
+                tail -n 20 "${NODE_LOG_PATH}"
+            else
+                echo "WARN: Massa Node log file is empty. The node might have failed to start or exited immediately."
+            fi
         else
-            echo "WARN: Massa Node log file is empty. The node might have failed to start or exited immediately."
+            echo "WARN: Massa Node log file NOT FOUND at ${NODE_LOG_PATH}. Node likely failed very early."
         fi
     else
-        echo "WARN: Massa Node log file NOT FOUND at ${NODE_LOG_PATH}. Node likely failed very early."
-    fi
-else
-    echo "ERROR: Failed to create/find Massa Node screen session ${NODE_SCREEN_NAME} (screen command exit code: ${NODE_SCREEN_EXIT_CODE})."
-    echo "This could be due to an immediate crash of massa-node or an issue with 'screen' itself."
-    echo "Checking for logs at ${NODE_LOG_PATH} (last 20 lines if file exists):"
-    if [ -f "${NODE_LOG_PATH}" ]; then
-        tail -n 20 "${NODE_LOG_PATH}"
-    else
-        echo "Node log file not found at ${NODE_LOG_PATH}."
+        echo "ERROR: Failed to create/find Massa Node screen session ${NODE_SCREEN_NAME} (screen command exit code: ${NODE_SCREEN_EXIT_CODE})."
+        echo "This could be due to an immediate crash of massa-node or an issue with 'screen' itself."
+        echo "Checking for logs at ${NODE_LOG_PATH} (last 20 lines if file exists):"
+        if [ -f "${NODE_LOG_PATH}" ]; then
+            tail -n 20 "${NODE_LOG_PATH}"
+        else
+            echo "Node log file not found at ${NODE_LOG_PATH}."
+        fi
     fi
 fi
 
@@ -398,13 +699,14 @@ if [ ! -f "${EXPECTED_CLIENT_DIR}/massa-client" ]; then
     # exit 1 # Don't exit if only client fails, node might be useful
 else
     chmod +x "${EXPECTED_CLIENT_DIR}/massa-client"
-    CLIENT_START_CMD="cd '${EXPECTED_CLIENT_DIR}' && ./massa-client -p '${NODE_PASSWORD}'"
+    CLIENT_START_CMD="cd '${EXPECTED_CLIENT_DIR}' && '${MASSA_RUNNER}' massa-client '${NODE_PASSWORD_DIR}/client_password'"
     echo "Executing in screen: screen -dmS ${CLIENT_SCREEN_NAME} /bin/bash -c \"${CLIENT_START_CMD}\""
-    screen -dmS "${CLIENT_SCREEN_NAME}" /bin/bash -c "${CLIENT_START_CMD}"
+    as_node_user screen -dmS "${CLIENT_SCREEN_NAME}" /bin/bash -c "${CLIENT_START_CMD}"
+    CLIENT_PASSWORD_HANDED=1
     sleep 3
 
     echo "Verifying client screen session ${CLIENT_SCREEN_NAME}..."
-    if screen -list | grep -q "${CLIENT_SCREEN_NAME}"; then
+    if as_node_user screen -list | grep -q "${CLIENT_SCREEN_NAME}"; then
         echo "SUCCESS: Massa Client screen session ${CLIENT_SCREEN_NAME} appears to be running."
     else
         echo "ERROR: Failed to confirm Massa Client screen session ${CLIENT_SCREEN_NAME} is running. Check server manually."
@@ -420,10 +722,37 @@ echo "To attach to client: screen -r ${CLIENT_SCREEN_NAME}"
     echo "(Inside screen, use Ctrl+A then D to detach)"
 echo "Node logs are at: ${NODE_LOG_PATH}"
 
-`, actualPublicIp)
+`, defaultMassaVersion, defaultInstallDir, shellQuote(actualPublicIp), strings.Join(massaWalletFiles, " "))
 
-	scriptPathOnServer := "/root/setup_massa_services.sh"
+	layout := srv.getLayout()
+	scriptPathOnServer := layout.setupScriptPath()
 	var logBuffer bytes.Buffer
+	for _, w := range ipWarnings {
+		logBuffer.WriteString("Warning: " + w + "\n")
+	}
+
+	// Step 0: Install the runner that types the password into the node and client
+	// prompts, and hand the password over in a private directory.
+	if err := a.installMassaRunner(serverID); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("Error: %v\n", err))
+		return logBuffer.String(), err
+	}
+	passwordDir, err := a.createSecretDir(serverID, map[string]string{
+		"node_password":   nodePassword + "\n",
+		"client_password": nodePassword + "\n",
+	})
+	if err != nil {
+		logBuffer.WriteString(fmt.Sprintf("Error: %v\n", err))
+		return logBuffer.String(), err
+	}
+	// Once the script runs, it owns the directory: the screens it starts read
+	// and delete their password files, and it removes the ones it did not use.
+	scriptStarted := false
+	defer func() {
+		if !scriptStarted {
+			a.removeSecretDir(serverID, passwordDir)
+		}
+	}()
 
 	// Step 1: Create/Update the script on the server using base64 encoding for safety
 	logBuffer.WriteString(fmt.Sprintf("Encoding script content and preparing to write to %s...\n", scriptPathOnServer))
@@ -431,9 +760,9 @@ echo "Node logs are at: ${NODE_LOG_PATH}"
 
 	// heredoc is generally safer for multiline strings if available and printf is tricky with complex content.
 	// However, for SSH commands, directly providing base64 encoded content to `base64 -d` is very robust.
-	writeCmd := fmt.Sprintf("echo '%s' | base64 -d > %s", encodedScript, scriptPathOnServer)
+	writeCmd := fmt.Sprintf("mkdir -p %s && echo '%s' | base64 -d > %s", shellQuote(layout.InstallDir), encodedScript, shellQuote(scriptPathOnServer))
 
-	output, err := a.RunCommand(writeCmd)
+	output, err := a.RunCommand(serverID, writeCmd)
 	// Output from echo | base64 -d > file is usually empty if successful
 	if strings.TrimSpace(output) != "" {
 		logBuffer.WriteString("Output from script write: " + output + "\n")
@@ -449,8 +778,8 @@ echo "Node logs are at: ${NODE_LOG_PATH}"
 
 	// Step 2: Make the script executable
 	logBuffer.WriteString("Making script executable...\n")
-	chmodCmd := fmt.Sprintf("chmod +x %s", scriptPathOnServer)
-	output, err = a.RunCommand(chmodCmd)
+	chmodCmd := "chmod +x " + shellQuote(scriptPathOnServer)
+	output, err = a.RunCommand(serverID, chmodCmd)
 	logBuffer.WriteString(output + "\n")
 	if err != nil {
 		errMsg := fmt.Sprintf("Error making script executable: %v. Output: %s", err, output)
@@ -459,15 +788,62 @@ echo "Node logs are at: ${NODE_LOG_PATH}"
 		return logBuffer.String(), fmt.Errorf("failed to chmod script: %w", err)
 	}
 
+	// A force reinstall deletes the wallet along with the installation, so it
+	// only goes ahead once the wallet files are backed up locally.
+	if forceReinstall {
+		logBuffer.WriteString("Backing up wallet files before force reinstall...\n")
+		backup, err := a.backupWallet(serverID, nodePassword, "reinstall")
+		if err != nil {
+			logBuffer.WriteString(fmt.Sprintf("Error: wallet backup failed: %v. Force reinstall aborted.\n", err))
+			return logBuffer.String(), fmt.Errorf("wallet backup before force reinstall failed: %w", err)
+		}
+		if backup == nil {
+			logBuffer.WriteString("No wallet files found, nothing to back up.\n")
+		} else {
+			logBuffer.WriteString(fmt.Sprintf("Wallet backed up to %s (%d file(s)).\n", backup.Path, len(backup.Files)))
+		}
+	}
+
 	// Step 3: Execute the script
-	logBuffer.WriteString(fmt.Sprintf("Executing script: %s with password [REDACTED], IP %s, Force Reinstall %t...\n", scriptPathOnServer, publicIp, forceReinstall))
-	// Pass password, IP, and forceReinstall flag as arguments to the script
+	serviceMode := srv.getServiceMode()
+	massaVersion := srv.getMassaVersion()
+	if upload != nil && upload.Version != "" {
+		massaVersion = upload.Version
+	}
+	archive, err := a.serverMassaArchive(serverID, massaVersion)
+	if err != nil {
+		logBuffer.WriteString(fmt.Sprintf("Error: %v\n", err))
+		return logBuffer.String(), err
+	}
+	logBuffer.WriteString(fmt.Sprintf("Executing script: %s with password file, IP %s, Force Reinstall %t, Service Mode %s, Version %s...\n", scriptPathOnServer, publicIp, forceReinstall, serviceMode, massaVersion))
+	// Pass the password directory, IP, forceReinstall flag, service mode and version as arguments to the script
 	forceReinstallStr := "false"
 	if forceReinstall {
 		forceReinstallStr = "true"
 	}
-	execCmd := fmt.Sprintf("%s '%s' '%s' '%s'", scriptPathOnServer, nodePassword, publicIp, forceReinstallStr)
-	scriptOutput, err := a.RunCommand(execCmd) // This will capture combined stdout/stderr from the script
+	// The script only needs the hash if it has to download the release, so a
+	// lookup failure is reported but does not stop a setup of an existing install.
+	expectedSHA256 := ""
+	localArchive := ""
+	unverified := ""
+	if upload != nil {
+		expectedSHA256, localArchive = upload.SHA256, upload.Path
+		if upload.VerifiedBy == "" {
+			unverified = "1"
+			logBuffer.WriteString(fmt.Sprintf("Installing uploaded archive %s, SHA-256 %s (not verified against a known checksum)\n", upload.Path, upload.SHA256))
+		} else {
+			logBuffer.WriteString(fmt.Sprintf("Installing uploaded archive %s, SHA-256 %s (verified against the %s checksum)\n", upload.Path, upload.SHA256, upload.VerifiedBy))
+		}
+	} else if checksum, err := a.resolveMassaArchiveChecksum(massaVersion, archive); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("Warning: %v\n", err))
+	} else {
+		expectedSHA256 = checksum.SHA256
+		logBuffer.WriteString(fmt.Sprintf("Expected SHA-256 of Massa %s: %s (%s)\n", massaVersion, expectedSHA256, checksum.Source))
+	}
+	execArgs := []string{scriptPathOnServer, passwordDir, actualPublicIp, forceReinstallStr, serviceMode, massaVersion, expectedSHA256,
+		layout.InstallDir, layout.RunAsUser, layout.DataDir, localArchive, unverified, archive}
+	scriptStarted = true
+	scriptOutput, err := a.runArgs(serverID, execArgs, nil) // This will capture combined stdout/stderr from the script
 	logBuffer.WriteString("\n--- Script Execution Output ---\n")
 	logBuffer.WriteString(scriptOutput + "\n")
 	logBuffer.WriteString("--- End of Script Execution Output ---\n")
@@ -482,6 +858,16 @@ echo "Node logs are at: ${NODE_LOG_PATH}"
 		return logBuffer.String(), fmt.Errorf("script execution reported an error: %w. Check script output for details.", err)
 	}
 
+	if serviceMode == serviceModeSystemd {
+		logBuffer.WriteString("\n--- Installing systemd service ---\n")
+		serviceLog, err := a.installMassaService(serverID, nodePassword)
+		logBuffer.WriteString(serviceLog)
+		if err != nil {
+			fmt.Printf("systemd service setup failed: %v\n", err)
+			return logBuffer.String(), fmt.Errorf("failed to set up systemd service: %w", err)
+		}
+	}
+
 	// Check for success messages from the script output to be more confident, even if err is nil
 	if strings.Contains(scriptOutput, "SUCCESS: Massa Node screen session") && strings.Contains(scriptOutput, "SUCCESS: Massa Client screen session") {
 		logBuffer.WriteString("\nINFO: Script reported successful start of Node and Client screen sessions.\n")
@@ -499,15 +885,15 @@ echo "Node logs are at: ${NODE_LOG_PATH}"
 }
 
 // CheckMassaNodeInstallation checks if the Massa node directory exists.
-func (a *App) CheckMassaNodeInstallation() (string, error) {
+func (a *App) CheckMassaNodeInstallation(serverID string) (string, error) {
 	fmt.Println("CheckMassaNodeInstallation called")
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
 	}
-	installBaseDir := "/root/massa_node"
-	expectedNodeDirOnServer := installBaseDir + "/massa/massa-node"
-	cmd := fmt.Sprintf("if [ -d \"%s\" ]; then echo 'INSTALLED'; else echo 'NOT_INSTALLED'; fi", expectedNodeDirOnServer)
-	output, err := a.RunCommand(cmd)
+	expectedNodeDirOnServer := srv.getLayout().nodeDir()
+	cmd := fmt.Sprintf("if [ -d %s ]; then echo 'INSTALLED'; else echo 'NOT_INSTALLED'; fi", shellQuote(expectedNodeDirOnServer))
+	output, err := a.runReadOnlyCommand(serverID, cmd)
 	trimmedOutput := strings.TrimSpace(output)
 	if err != nil {
 		if trimmedOutput == "INSTALLED" {
@@ -528,10 +914,10 @@ func (a *App) CheckMassaNodeInstallation() (string, error) {
 }
 
 // GetServerStats retrieves basic server resource information.
-func (a *App) GetServerStats() (string, error) {
+func (a *App) GetServerStats(serverID string) (string, error) {
 	fmt.Println("GetServerStats called")
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
+	if _, err := a.server(serverID); err != nil {
+		return "Error: No active SSH connection.", err
 	}
 
 	var fullOutput strings.Builder
@@ -550,7 +936,7 @@ func (a *App) GetServerStats() (string, error) {
 
 	for name, cmd := range commands {
 		// Run the command silently without logging details to the output
-		output, err := a.RunCommand(cmd)
+		output, err := a.runReadOnlyCommand(serverID, cmd)
 		trimmedOutput := strings.TrimSpace(output)
 
 		if err != nil {
@@ -590,7 +976,7 @@ func (a *App) GetServerStats() (string, error) {
 
 	// Get list of running processes by CPU usage
 	topProcessesCmd := "ps -eo pid,pcpu,pmem,comm --sort=-pcpu | head -n 6"
-	topOutput, topErr := a.RunCommand(topProcessesCmd)
+	topOutput, topErr := a.runReadOnlyCommand(serverID, topProcessesCmd)
 	if topErr == nil {
 		fullOutput.WriteString("Top Processes (by CPU):\n")
 		fullOutput.WriteString(topOutput)
@@ -599,7 +985,7 @@ func (a *App) GetServerStats() (string, error) {
 
 	// Get screen sessions
 	screenListCmd := "screen -ls"
-	screenOutput, screenErr := a.RunCommand(screenListCmd)
+	screenOutput, screenErr := a.runReadOnlyCommand(serverID, screenListCmd)
 	if screenErr == nil && strings.Contains(screenOutput, "Socket") {
 		fullOutput.WriteString("Active Screen Sessions:\n")
 		fullOutput.WriteString(screenOutput)
@@ -612,120 +998,52 @@ func (a *App) GetServerStats() (string, error) {
 	return log, lastError
 }
 
-// CheckMassaNodeStatus checks the live status of the Massa node screen session and its logs.
-// Returns "RUNNING", "STOPPED_WITH_LOGS", "STOPPED_NO_LOGS", "NOT_INSTALLED", or an error string.
-func (a *App) CheckMassaNodeStatus() (string, error) {
+// CheckMassaNodeStatus returns the node's state as a code: NOT_INSTALLED,
+// RUNNING, STOPPED_WITH_LOGS, STOPPED_EMPTY_LOG or STOPPED_NO_LOGS. GetNodeStatus
+// has the details.
+func (a *App) CheckMassaNodeStatus(serverID string) (string, error) {
 	fmt.Println("CheckMassaNodeStatus called")
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
-	}
-
-	installBaseDir := "/root/massa_node"
-	expectedNodeDir := installBaseDir + "/massa/massa-node"
-	nodeLogPath := expectedNodeDir + "/logs.txt"
-	nodeScreenName := "massa_node"
-
-	// 1. Check if the base installation directory and massa-node executable exist
-	checkInstallCmd := fmt.Sprintf("if [ -d \"%s\" ] && [ -f \"%s/massa-node\" ]; then echo 'INSTALLED_EXE_FOUND'; else echo 'NOT_INSTALLED_OR_EXE_MISSING'; fi", expectedNodeDir, expectedNodeDir)
-	installStatusOutput, err := a.RunCommand(checkInstallCmd)
-	trimmedInstallStatus := strings.TrimSpace(installStatusOutput)
-
+	status, err := a.GetNodeStatus(serverID)
 	if err != nil {
-		// If the command itself failed, it might be a connection issue or deeper problem.
-		// However, if the output indicates not installed, we trust that.
-		if trimmedInstallStatus == "NOT_INSTALLED_OR_EXE_MISSING" {
-			fmt.Println("Node not installed or executable missing (based on error path).")
-			return "NOT_INSTALLED", nil
-		}
-		return fmt.Sprintf("Error checking installation presence: %v. Output: %s", err, trimmedInstallStatus), err
-	}
-
-	if trimmedInstallStatus == "NOT_INSTALLED_OR_EXE_MISSING" {
-		fmt.Println("Node not installed or executable missing.")
-		return "NOT_INSTALLED", nil
-	}
-
-	// 2. Check if the screen session is running
-	// The grep command will have an exit status of 0 if found, 1 if not found.
-	// For simple screen names without spaces, direct injection is usually fine.
-	// If screen names could have spaces or special characters, using sh -c "grep 'name'" would be more robust.
-	checkScreenCmd := fmt.Sprintf("screen -list | grep -q %s", nodeScreenName)
-	screenOutput, screenErr := a.RunCommand(checkScreenCmd)
-
-	isScreenRunning := false
-	if screenErr == nil {
-		// Exit status 0 means grep found the screen session
-		isScreenRunning = true
-		fmt.Printf("Screen session '%s' is running. Output: %s\n", nodeScreenName, screenOutput)
-	} else {
-		// Exit status non-zero from grep means not found (or other error, but usually not found)
-		fmt.Printf("Screen session '%s' not found or grep error. Error: %v, Output: %s\n", nodeScreenName, screenErr, screenOutput)
-	}
-
-	// 3. Check the log file (even if screen is not running, logs might indicate past activity or recent crash)
-	checkLogCmd := fmt.Sprintf("if [ -f \"%s\" ] && [ -s \"%s\" ]; then echo 'LOG_EXISTS_AND_NOT_EMPTY'; elif [ -f \"%s\" ]; then echo 'LOG_EXISTS_BUT_EMPTY'; else echo 'LOG_NOT_FOUND'; fi", nodeLogPath, nodeLogPath, nodeLogPath)
-	logStatusOutput, logErr := a.RunCommand(checkLogCmd)
-	trimmedLogStatus := strings.TrimSpace(logStatusOutput)
-
-	if logErr != nil {
-		// This is an error in the command to check the log, not necessarily that the log doesn't exist.
-		// However, we can still try to infer based on the screen status.
-		fmt.Printf("Error checking log status: %v. Output: %s\n", logErr, trimmedLogStatus)
-		if isScreenRunning {
-			// If screen is running but we can't check logs, it's odd. Report as running but with log issue.
-			return "RUNNING_LOG_CHECK_ERROR", nil
-		}
-		// If screen not running and log check failed, be cautious.
-		return "STOPPED_LOG_CHECK_ERROR", nil
-	}
-
-	if isScreenRunning {
-		// If screen is running, the primary status is RUNNING. Log status is secondary.
-		return "RUNNING", nil
-	}
-
-	// Screen is not running at this point. Determine status based on logs.
-	switch trimmedLogStatus {
-	case "LOG_EXISTS_AND_NOT_EMPTY":
-		fmt.Println("Screen not running, but log file exists and is not empty.")
-		return "STOPPED_WITH_LOGS", nil
-	case "LOG_EXISTS_BUT_EMPTY":
-		fmt.Println("Screen not running, and log file exists but is empty.")
-		return "STOPPED_EMPTY_LOG", nil
-	case "LOG_NOT_FOUND":
-		fmt.Println("Screen not running, and log file not found.")
-		return "STOPPED_NO_LOGS", nil // This might also imply it was never run or cleaned.
-	default:
-		fmt.Printf("Screen not running, and unexpected log status: %s\n", trimmedLogStatus)
-		return "STOPPED_UNKNOWN_LOG_STATUS", nil
+		return fmt.Sprintf("Error checking node status: %v", err), err
 	}
+	return status.State, nil
 }
 
 // StartMassaNode starts the Massa node when it's installed but not running
-func (a *App) StartMassaNode(nodePassword string) (string, error) {
+func (a *App) StartMassaNode(serverID string, nodePassword string) (string, error) {
 	fmt.Println("StartMassaNode called")
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
 	}
 
 	if nodePassword == "" {
 		return "Error: Node password is required to start Massa node.", fmt.Errorf("node password is required")
 	}
+	if err := validNodePassword(nodePassword); err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
 
 	// Save the node password for future use with massa-client
-	a.nodePassword = nodePassword
+	srv.setNodePassword(nodePassword)
+
+	if srv.getServiceMode() == serviceModeSystemd {
+		return a.startMassaService(serverID)
+	}
 
 	// Define paths and screen names
-	installBaseDir := "/root/massa_node"
-	expectedNodeDir := installBaseDir + "/massa/massa-node"
-	expectedClientDir := installBaseDir + "/massa/massa-client"
-	nodeLogPath := expectedNodeDir + "/logs.txt"
+	layout := srv.getLayout()
+	expectedNodeDir := layout.nodeDir()
+	expectedClientDir := layout.clientDir()
+	nodeLogPath := layout.nodeLogPath()
 	nodeScreenName := "massa_node"
 	clientScreenName := "massa_client"
 
 	// Check if node is installed first
-	checkInstallCmd := fmt.Sprintf("if [ -d \"%s\" ] && [ -f \"%s/massa-node\" ]; then echo 'INSTALLED'; else echo 'NOT_INSTALLED'; fi", expectedNodeDir, expectedNodeDir)
-	installStatusOutput, err := a.RunCommand(checkInstallCmd)
+	checkInstallCmd := fmt.Sprintf("if [ -d %s ] && [ -f %s ]; then echo 'INSTALLED'; else echo 'NOT_INSTALLED'; fi",
+		shellQuote(expectedNodeDir), shellQuote(expectedNodeDir+"/massa-node"))
+	installStatusOutput, err := a.RunCommand(serverID, checkInstallCmd)
 	trimmedInstallStatus := strings.TrimSpace(installStatusOutput)
 
 	if err != nil || trimmedInstallStatus != "INSTALLED" {
@@ -736,8 +1054,8 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 	logBuffer.WriteString("Starting Massa node...\n")
 
 	// Check if screens are already running
-	checkNodeScreenCmd := fmt.Sprintf("screen -list | grep -q %s", nodeScreenName)
-	_, nodeScreenErr := a.RunCommand(checkNodeScreenCmd)
+	checkNodeScreenCmd := srv.asNodeUser(fmt.Sprintf("screen -list | grep -q %s", nodeScreenName))
+	_, nodeScreenErr := a.RunCommand(serverID, checkNodeScreenCmd)
 
 	if nodeScreenErr == nil {
 		logBuffer.WriteString("Massa node screen is already running. No need to start.\n")
@@ -745,8 +1063,8 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 	}
 
 	// Ensure the node executable is executable
-	chmodCmd := fmt.Sprintf("chmod +x %s/massa-node", expectedNodeDir)
-	_, chmodErr := a.RunCommand(chmodCmd)
+	chmodCmd := shellJoin("chmod", "+x", expectedNodeDir+"/massa-node")
+	_, chmodErr := a.RunCommand(serverID, chmodCmd)
 	if chmodErr != nil {
 		errMsg := fmt.Sprintf("Failed to make node executable: %v", chmodErr)
 		logBuffer.WriteString(errMsg + "\n")
@@ -754,22 +1072,49 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 	}
 
 	// Create log directory and clear old log if it exists
-	rmLogCmd := fmt.Sprintf("rm -f %s && touch %s", nodeLogPath, nodeLogPath)
-	_, rmLogErr := a.RunCommand(rmLogCmd)
+	rmLogCmd := srv.asNodeUser(shellJoin("rm", "-f", nodeLogPath) + " && " + shellJoin("touch", nodeLogPath))
+	_, rmLogErr := a.RunCommand(serverID, rmLogCmd)
 	if rmLogErr != nil {
 		logBuffer.WriteString(fmt.Sprintf("Warning: Failed to clear old log file: %v\n", rmLogErr))
 	}
 
-	// Start Massa Node in a screen session
-	nodeStartCmd := fmt.Sprintf("cd '%s' && screen -dmS %s /bin/bash -c './massa-node -p \"%s\" |& tee \"%s\"'",
-		expectedNodeDir, nodeScreenName, nodePassword, nodeLogPath)
-	_, nodeStartErr := a.RunCommand(nodeStartCmd)
+	// Start Massa Node in a screen session. The password is typed into the
+	// node's prompt by the runner, from a file it deletes as soon as it is read.
+	if err := a.installMassaRunner(serverID); err != nil {
+		logBuffer.WriteString(err.Error() + "\n")
+		return logBuffer.String(), err
+	}
+	passwordDir, err := a.createSecretDir(serverID, map[string]string{
+		"node_password":   nodePassword + "\n",
+		"client_password": nodePassword + "\n",
+	})
+	if err != nil {
+		logBuffer.WriteString(err.Error() + "\n")
+		return logBuffer.String(), err
+	}
+	// A runner deletes its password file once read, which in a detached screen
+	// may be after this returns. Only files no runner was started with are
+	// removed here.
+	nodeStarted, clientStarted := false, false
+	defer func() {
+		switch {
+		case !nodeStarted:
+			a.removeSecretDir(serverID, passwordDir)
+		case !clientStarted:
+			a.removeSecretFile(serverID, passwordDir+"/client_password")
+		}
+	}()
+
+	nodeRunCmd := shellJoin(layout.runnerPath(), "massa-node", passwordDir+"/node_password") + " |& tee " + shellQuote(nodeLogPath)
+	nodeStartCmd := srv.asNodeUser("cd " + shellQuote(expectedNodeDir) + " && " + shellJoin("screen", "-dmS", nodeScreenName, "/bin/bash", "-c", nodeRunCmd))
+	_, nodeStartErr := a.RunCommand(serverID, nodeStartCmd)
 
 	if nodeStartErr != nil {
 		errMsg := fmt.Sprintf("Failed to start Massa node: %v", nodeStartErr)
 		logBuffer.WriteString(errMsg + "\n")
 		return logBuffer.String(), fmt.Errorf(errMsg)
 	}
+	nodeStarted = true
 
 	logBuffer.WriteString("Massa node screen started. Waiting to verify...\n")
 
@@ -777,7 +1122,7 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 	time.Sleep(5 * time.Second)
 
 	// Verify node screen is running
-	_, nodeCheckErr := a.RunCommand(checkNodeScreenCmd)
+	_, nodeCheckErr := a.RunCommand(serverID, checkNodeScreenCmd)
 	if nodeCheckErr != nil {
 		logBuffer.WriteString("Warning: Could not verify node screen is running after start attempt.\n")
 	} else {
@@ -785,35 +1130,36 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 	}
 
 	// Also start the client if available
-	checkClientExeCmd := fmt.Sprintf("if [ -f \"%s/massa-client\" ]; then echo 'CLIENT_FOUND'; else echo 'CLIENT_NOT_FOUND'; fi", expectedClientDir)
-	clientExeOutput, _ := a.RunCommand(checkClientExeCmd)
+	checkClientExeCmd := fmt.Sprintf("if [ -f %s ]; then echo 'CLIENT_FOUND'; else echo 'CLIENT_NOT_FOUND'; fi", shellQuote(expectedClientDir+"/massa-client"))
+	clientExeOutput, _ := a.RunCommand(serverID, checkClientExeCmd)
 	trimmedClientExe := strings.TrimSpace(clientExeOutput)
 
 	if trimmedClientExe == "CLIENT_FOUND" {
 		logBuffer.WriteString("Massa client found. Attempting to start client screen...\n")
 
 		// Check if client screen is already running
-		checkClientScreenCmd := fmt.Sprintf("screen -list | grep -q %s", clientScreenName)
-		_, clientScreenErr := a.RunCommand(checkClientScreenCmd)
+		checkClientScreenCmd := srv.asNodeUser(fmt.Sprintf("screen -list | grep -q %s", clientScreenName))
+		_, clientScreenErr := a.RunCommand(serverID, checkClientScreenCmd)
 
 		if clientScreenErr == nil {
 			logBuffer.WriteString("Massa client screen is already running.\n")
 		} else {
 			// Make client executable
-			chmodClientCmd := fmt.Sprintf("chmod +x %s/massa-client", expectedClientDir)
-			_, chmodClientErr := a.RunCommand(chmodClientCmd)
+			chmodClientCmd := shellJoin("chmod", "+x", expectedClientDir+"/massa-client")
+			_, chmodClientErr := a.RunCommand(serverID, chmodClientCmd)
 			if chmodClientErr != nil {
 				logBuffer.WriteString(fmt.Sprintf("Warning: Failed to make client executable: %v\n", chmodClientErr))
 			}
 
-			// Start client screen
-			clientStartCmd := fmt.Sprintf("cd '%s' && screen -dmS %s /bin/bash -c './massa-client -p \"%s\"'",
-				expectedClientDir, clientScreenName, nodePassword)
-			_, clientStartErr := a.RunCommand(clientStartCmd)
+			// Start client screen, with the password typed in by the runner as for the node
+			clientRunCmd := shellJoin(layout.runnerPath(), "massa-client", passwordDir+"/client_password")
+			clientStartCmd := srv.asNodeUser("cd " + shellQuote(expectedClientDir) + " && " + shellJoin("screen", "-dmS", clientScreenName, "/bin/bash", "-c", clientRunCmd))
+			_, clientStartErr := a.RunCommand(serverID, clientStartCmd)
 
 			if clientStartErr != nil {
 				logBuffer.WriteString(fmt.Sprintf("Warning: Failed to start Massa client: %v\n", clientStartErr))
 			} else {
+				clientStarted = true
 				logBuffer.WriteString("Massa client screen started.\n")
 			}
 		}
@@ -831,18 +1177,23 @@ func (a *App) StartMassaNode(nodePassword string) (string, error) {
 }
 
 // GetMassaNodeLogs fetches the logs from the massa_node screen session.
-func (a *App) GetMassaNodeLogs() (string, error) {
+func (a *App) GetMassaNodeLogs(serverID string) (string, error) {
 	fmt.Println("Fetching Massa node logs...")
-	if a.sshClient == nil {
+	srv, err := a.server(serverID)
+	if err != nil {
 		errMsg := "Error: No active SSH connection."
 		fmt.Println(errMsg)
-		return errMsg, fmt.Errorf(errMsg)
+		return errMsg, err
+	}
+
+	if srv.getServiceMode() == serviceModeSystemd {
+		return a.massaServiceLogs(serverID)
 	}
 
 	// Command to get the most recent logs from the massa_node screen session
 	// We use "screen -S massa_node -X hardcopy /tmp/massa_node_logs.txt" to create a snapshot of the screen
 	// and then read the file content
-	command := `
+	command := srv.asNodeUser(`
 if screen -list | grep -q "massa_node"; then
   # Create a snapshot of the screen content
   screen -S massa_node -X hardcopy /tmp/massa_node_logs.txt
@@ -860,9 +1211,13 @@ if screen -list | grep -q "massa_node"; then
 else
   echo "Massa node screen session not found."
 fi
-`
+`)
 
-	session, err := a.sshClient.NewSession()
+	client, err := srv.sshClient()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+	session, err := client.NewSession()
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to create session: %v", err)
 		fmt.Println(errMsg)
@@ -898,58 +1253,76 @@ fi
 }
 
 // GetWalletInfo retrieves wallet information from the Massa client
-func (a *App) GetWalletInfo() (string, error) {
+func (a *App) GetWalletInfo(serverID string) (string, error) {
 	fmt.Println("Getting wallet information...")
-	return a.RunMassaClientCommand("wallet_info")
+	return a.RunMassaClientCommand(serverID, "wallet_info")
 }
 
 // GenerateWalletKey generates a new wallet key in the Massa client
-func (a *App) GenerateWalletKey() (string, error) {
+func (a *App) GenerateWalletKey(serverID string) (string, error) {
 	fmt.Println("Generating new wallet key...")
-	return a.RunMassaClientCommand("wallet_generate_secret_key")
+	return a.RunMassaClientCommand(serverID, "wallet_generate_secret_key")
 }
 
 // ImportWalletKey imports a wallet key into the Massa client
-func (a *App) ImportWalletKey(secretKey string) (string, error) {
+func (a *App) ImportWalletKey(serverID string, secretKey string) (string, error) {
 	fmt.Println("Importing wallet key...")
-	return a.RunMassaClientCommand("wallet_add_secret_keys " + secretKey)
+	if err := validateClientArg("secret key", secretKey); err != nil {
+		return "Error: " + err.Error(), err
+	}
+	release := a.secrets.hold(secretKey)
+	defer release()
+	return a.RunMassaClientCommand(serverID, "wallet_add_secret_keys "+secretKey)
 }
 
 // GetAddressPublicKey gets the public key for specified addresses
-func (a *App) GetAddressPublicKey(address string) (string, error) {
+func (a *App) GetAddressPublicKey(serverID string, address string) (string, error) {
 	fmt.Println("Getting public key for address:", address)
-	return a.RunMassaClientCommand("wallet_get_public_key " + address)
+	if err := validateClientArg("address", address); err != nil {
+		return "Error: " + err.Error(), err
+	}
+	return a.RunMassaClientCommand(serverID, "wallet_get_public_key "+address)
 }
 
 // BuyRolls buys rolls (stake) for a wallet address
-func (a *App) BuyRolls(address string, rollCount int, fee float64) (string, error) {
+func (a *App) BuyRolls(serverID string, address string, rollCount int, fee float64) (string, error) {
 	fmt.Printf("Buying %d rolls for address %s with fee %f\n", rollCount, address, fee)
+	if err := validateClientArg("address", address); err != nil {
+		return "Error: " + err.Error(), err
+	}
 	cmd := fmt.Sprintf("buy_rolls %s %d %f", address, rollCount, fee)
-	return a.RunMassaClientCommand(cmd)
+	return a.RunMassaClientCommand(serverID, cmd)
 }
 
 // SellRolls sells rolls (unstake) for a wallet address
-func (a *App) SellRolls(address string, rollCount int, fee float64) (string, error) {
+func (a *App) SellRolls(serverID string, address string, rollCount int, fee float64) (string, error) {
 	fmt.Printf("Selling %d rolls for address %s with fee %f\n", rollCount, address, fee)
+	if err := validateClientArg("address", address); err != nil {
+		return "Error: " + err.Error(), err
+	}
 	cmd := fmt.Sprintf("sell_rolls %s %d %f", address, rollCount, fee)
-	return a.RunMassaClientCommand(cmd)
+	return a.RunMassaClientCommand(serverID, cmd)
 }
 
 // StartStaking starts staking with a wallet address
-func (a *App) StartStaking(address string) (string, error) {
+func (a *App) StartStaking(serverID string, address string) (string, error) {
 	fmt.Println("Starting staking with address:", address)
-	return a.RunMassaClientCommand("node_start_staking " + address)
+	if err := validateClientArg("address", address); err != nil {
+		return "Error: " + err.Error(), err
+	}
+	return a.RunMassaClientCommand(serverID, "node_start_staking "+address)
 }
 
 // RunMassaClientCommand runs a command in the Massa client with a more reliable approach
-func (a *App) RunMassaClientCommand(command string) (string, error) {
-	if a.sshClient == nil {
-		return "Error: No active SSH connection.", fmt.Errorf("no active SSH connection")
+func (a *App) RunMassaClientCommand(serverID string, command string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
 	}
 
 	// Find the massa-client directory
-	findClientDirCmd := "find /root/massa_node/massa -name massa-client -type d 2>/dev/null"
-	clientDirOutput, err := a.RunCommand(findClientDirCmd)
+	findClientDirCmd := fmt.Sprintf("find %s -name massa-client -type d 2>/dev/null", shellQuote(srv.getLayout().massaDir()))
+	clientDirOutput, err := a.RunCommand(serverID, findClientDirCmd)
 	if err != nil || clientDirOutput == "" {
 		return "Error: Could not find massa-client directory.", fmt.Errorf("massa-client directory not found")
 	}
@@ -958,40 +1331,28 @@ func (a *App) RunMassaClientCommand(command string) (string, error) {
 	clientDir := strings.TrimSpace(clientDirOutput)
 	fmt.Printf("Found massa-client directory: %s\n", clientDir)
 
-	// Create a temporary script to execute the command and capture its output
-	scriptContent := fmt.Sprintf(`#!/bin/bash
-cd "%s"
-PASSWORD="%s"
-COMMAND="%s"
-
-# Execute massa-client command and capture output
-./massa-client -p "$PASSWORD" <<EOF | tee /tmp/massa_client_result.txt
-$COMMAND
-exit
-EOF
-
-cat /tmp/massa_client_result.txt
-`, clientDir, a.nodePassword, command)
-
-	// Create a temporary script file
-	scriptPath := "/tmp/run_massa_client.sh"
-	createScriptCmd := fmt.Sprintf("cat > %s << 'EOFSCRIPT'\n%s\nEOFSCRIPT", scriptPath, scriptContent)
-	_, err = a.RunCommand(createScriptCmd)
-	if err != nil {
-		return fmt.Sprintf("Error creating temporary script: %v", err), err
+	// The client reads one command per line, so a newline would smuggle in a second command.
+	if strings.ContainsAny(command, "\r\n") {
+		return "Error: massa-client command must be a single line.", fmt.Errorf("massa-client command must be a single line")
 	}
 
-	// Make script executable
-	_, err = a.RunCommand(fmt.Sprintf("chmod +x %s", scriptPath))
+	// The password and the command (which may carry a secret key) go into a
+	// private directory; the runner types them into the client and deletes them.
+	if err := a.installMassaRunner(serverID); err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+	secretDir, err := a.createSecretDir(serverID, map[string]string{
+		"password": srv.getNodePassword() + "\n",
+		"input":    command + "\nexit\n",
+	})
 	if err != nil {
-		return fmt.Sprintf("Error making script executable: %v", err), err
+		return fmt.Sprintf("Error: %v", err), err
 	}
+	defer a.removeSecretDir(serverID, secretDir)
 
-	// Execute the script
-	output, err := a.RunCommand(scriptPath)
-
-	// Clean up the script
-	a.RunCommand(fmt.Sprintf("rm -f %s /tmp/massa_client_result.txt", scriptPath))
+	runCmd := srv.asNodeUser("cd " + shellQuote(clientDir) + " && " + shellJoin(srv.getLayout().runnerPath(), "massa-client", secretDir+"/password", secretDir+"/input"))
+	output, err := a.RunCommand(serverID, runCmd)
+	output = strings.ReplaceAll(output, "\r", "")
 
 	if err != nil {
 		return fmt.Sprintf("Error executing massa-client command: %v\nOutput: %s", err, output), err
@@ -1005,7 +1366,7 @@ cat /tmp/massa_client_result.txt
 
 	for _, line := range lines {
 		// Skip prompt/header lines
-		if strings.Contains(line, "Enter password:") ||
+		if strings.Contains(strings.ToLower(line), "password") ||
 			strings.Contains(line, "wallet_info") ||
 			strings.Contains(line, "wallet_generate_secret_key") ||
 			strings.Contains(line, "wallet_add_secret_keys") ||
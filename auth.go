@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Authentication method names accepted in AuthOptions.Methods. They match the
+// method names used by the SSH protocol, except for "agent", which is a
+// public-key method backed by a running ssh-agent.
+const (
+	authMethodAgent               = "agent"
+	authMethodPublicKey           = "publickey"
+	authMethodPassword            = "password"
+	authMethodKeyboardInteractive = "keyboard-interactive"
+)
+
+// defaultAuthMethods is the fallback chain used when AuthOptions.Methods is empty.
+var defaultAuthMethods = []string{authMethodAgent, authMethodPublicKey, authMethodPassword, authMethodKeyboardInteractive}
+
+// defaultKeyFiles are tried, in order, when public-key auth is requested without a KeyPath.
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// AuthOptions describes how to authenticate against a server.
+type AuthOptions struct {
+	// Methods is the ordered chain of methods to try ("agent", "publickey",
+	// "password", "keyboard-interactive"). Empty means defaultAuthMethods.
+	Methods       []string `json:"methods"`
+	Password      string   `json:"password"`
+	KeyPath       string   `json:"keyPath"`       // Private key file (ed25519, RSA or ECDSA)
+	KeyPassphrase string   `json:"keyPassphrase"` // Only needed for encrypted keys
+}
+
+// AuthPromptQuestion is a single question asked by the server during keyboard-interactive auth.
+type AuthPromptQuestion struct {
+	Text string `json:"text"`
+	Echo bool   `json:"echo"`
+}
+
+// AuthPrompt is sent to the frontend (event "auth:prompt") when the server asks
+// keyboard-interactive questions we cannot answer ourselves, e.g. a 2FA code.
+type AuthPrompt struct {
+	ID          string               `json:"id"`
+	User        string               `json:"user"`
+	Name        string               `json:"name"`
+	Instruction string               `json:"instruction"`
+	Questions   []AuthPromptQuestion `json:"questions"`
+}
+
+// buildAuthMethods turns opts into the ssh.AuthMethod chain for a connection.
+// The returned cleanup function releases the ssh-agent connection, if one was
+// opened, and must be called once the handshake is finished. Without
+// interactive, keyboard-interactive questions are never forwarded to the user.
+func (a *App) buildAuthMethods(user string, opts AuthOptions, interactive bool) ([]ssh.AuthMethod, func(), error) {
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = defaultAuthMethods
+	}
+
+	var authMethods []ssh.AuthMethod
+	var signers []ssh.Signer
+	var agentConn net.Conn
+	cleanup := func() {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+	}
+	// The SSH client only tries each protocol method once, so key files and
+	// agent keys are merged into a single "publickey" method at the position
+	// of whichever of the two comes first in the chain.
+	publicKeyAdded := false
+	addPublicKey := func() {
+		if publicKeyAdded {
+			return
+		}
+		publicKeyAdded = true
+		authMethods = append(authMethods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			return signers, nil
+		}))
+	}
+
+	for _, method := range methods {
+		switch method {
+		case authMethodAgent:
+			conn, agentSigners, err := agentSignersFromEnv()
+			if err != nil {
+				fmt.Printf("ssh-agent not used: %v\n", err)
+				continue
+			}
+			agentConn = conn
+			signers = append(signers, agentSigners...)
+			addPublicKey()
+
+		case authMethodPublicKey:
+			keySigners, err := loadKeySigners(opts.KeyPath, opts.KeyPassphrase)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			signers = append(signers, keySigners...)
+			addPublicKey()
+
+		case authMethodPassword:
+			if opts.Password == "" {
+				continue
+			}
+			authMethods = append(authMethods, ssh.Password(opts.Password))
+
+		case authMethodKeyboardInteractive:
+			authMethods = append(authMethods, ssh.KeyboardInteractive(a.keyboardInteractiveChallenge(user, opts.Password, interactive)))
+
+		default:
+			cleanup()
+			return nil, nil, fmt.Errorf("unknown authentication method %q", method)
+		}
+	}
+
+	if len(authMethods) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("no usable authentication method configured")
+	}
+	return authMethods, cleanup, nil
+}
+
+// agentSignersFromEnv connects to the ssh-agent pointed to by SSH_AUTH_SOCK and
+// returns its keys. The caller owns the returned connection.
+func agentSignersFromEnv() (net.Conn, []ssh.Signer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to list ssh-agent keys: %w", err)
+	}
+	if len(signers) == 0 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("ssh-agent has no keys loaded")
+	}
+	return conn, signers, nil
+}
+
+// loadKeySigners loads the private key at keyPath. When keyPath is empty the
+// usual ~/.ssh identities are tried instead and encrypted ones are skipped
+// unless a passphrase was given.
+func loadKeySigners(keyPath string, passphrase string) ([]ssh.Signer, error) {
+	if keyPath != "" {
+		signer, err := loadPrivateKey(expandHome(keyPath), passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	var signers []ssh.Signer
+	for _, name := range defaultKeyFiles {
+		path := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		signer, err := loadPrivateKey(path, passphrase)
+		if err != nil {
+			fmt.Printf("Skipping default key %s: %v\n", path, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// loadPrivateKey parses an OpenSSH/PEM private key, decrypting it with passphrase if needed.
+func loadPrivateKey(path string, passphrase string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("private key %s is passphrase protected, please provide the passphrase", path)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("wrong passphrase for private key %s", path)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// keyboardInteractiveChallenge answers password-style questions with the stored
+// password and forwards anything else (OTP codes, etc.) to the user, or fails
+// when not interactive.
+func (a *App) keyboardInteractiveChallenge(user string, password string, interactive bool) ssh.KeyboardInteractiveChallenge {
+	passwordUsed := false
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if len(questions) == 0 {
+			return []string{}, nil
+		}
+
+		if password != "" && !passwordUsed && len(questions) == 1 && !echos[0] &&
+			strings.Contains(strings.ToLower(questions[0]), "password") {
+			passwordUsed = true
+			return []string{password}, nil
+		}
+		if !interactive {
+			return nil, fmt.Errorf("server asked for more than the password; reconnect manually")
+		}
+
+		prompt := AuthPrompt{User: user, Name: name, Instruction: instruction}
+		for i, q := range questions {
+			prompt.Questions = append(prompt.Questions, AuthPromptQuestion{Text: q, Echo: echos[i]})
+		}
+		reply, err := a.askFrontend("auth:prompt", func(id string) interface{} {
+			prompt.ID = id
+			return prompt
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !reply.Accept {
+			return nil, fmt.Errorf("authentication cancelled by the user")
+		}
+		if len(reply.Answers) != len(questions) {
+			return nil, fmt.Errorf("expected %d answers, got %d", len(questions), len(reply.Answers))
+		}
+		return reply.Answers, nil
+	}
+}
+
+// AnswerAuthPrompt answers an "auth:prompt" emitted during keyboard-interactive
+// authentication. Passing no answers cancels the login.
+func (a *App) AnswerAuthPrompt(promptID string, answers []string) error {
+	return a.prompts.resolve(promptID, promptReply{Accept: answers != nil, Answers: answers})
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// massaPinnedChecksums is the manifest of release archive hashes shipped with
+// the manager, keyed by archive name so every architecture of a release has
+// its own entry. An entry is only added after checking the archive against
+// the hash published with the release. Hashes pinned by the user
+// (PinMassaReleaseChecksum) take precedence.
+var massaPinnedChecksums = map[string]string{}
+
+// massaChecksumManifests are the names of release assets listing the hashes
+// of several archives. Other checksum assets must be named after the archive
+// they cover, e.g. "<archive>.sha256".
+var massaChecksumManifests = []string{"sha256sums", "sha256sums.txt", "checksums.txt", "checksums.sha256"}
+
+var sha256HexPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Checksum sources reported in MassaReleaseChecksum.
+const (
+	checksumSourcePinned       = "pinned"
+	checksumSourceUserPinned   = "user-pinned"
+	checksumSourceAssetDigest  = "github-digest"
+	checksumSourceChecksumFile = "checksum-file"
+	checksumSourceUserSupplied = "user-supplied" // Given for a single install, see InstallMassaFromLocal
+)
+
+// MassaReleaseChecksum is the expected SHA-256 of a release archive and where it came from.
+type MassaReleaseChecksum struct {
+	Version string `json:"version"`
+	Archive string `json:"archive"`
+	SHA256  string `json:"sha256"`
+	Source  string `json:"source"`
+}
+
+// InstalledMassaRelease is one entry of the install history kept on the server.
+type InstalledMassaRelease struct {
+	Version     string    `json:"version"`
+	SHA256      string    `json:"sha256"`
+	Verified    bool      `json:"verified"` // False for uploads installed without a known checksum
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// checksumStore keeps the hashes pinned by the user in a JSON file inside the
+// manager's config directory.
+type checksumStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newChecksumStore(path string) *checksumStore {
+	return &checksumStore{path: path}
+}
+
+func (s *checksumStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned checksums: %w", err)
+	}
+	checksums := map[string]string{}
+	if err := json.Unmarshal(data, &checksums); err != nil {
+		return nil, fmt.Errorf("failed to parse pinned checksums: %w", err)
+	}
+	return checksums, nil
+}
+
+func (s *checksumStore) get(version string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checksums, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	return checksums[version], nil
+}
+
+func (s *checksumStore) set(version string, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checksums, err := s.load()
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		delete(checksums, version)
+	} else {
+		checksums[version] = hash
+	}
+	data, err := json.MarshalIndent(checksums, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// normalizeSHA256 accepts a hex SHA-256, optionally prefixed with "sha256:".
+func normalizeSHA256(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	hash = strings.TrimPrefix(hash, "sha256:")
+	if !sha256HexPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid SHA-256 %q", hash)
+	}
+	return hash, nil
+}
+
+// PinMassaReleaseChecksum records the SHA-256 to expect for the archive of
+// version, overriding anything published with the release. An empty hash
+// removes the pin.
+func (a *App) PinMassaReleaseChecksum(version string, hash string) error {
+	version, err := validMassaVersion(version)
+	if err != nil {
+		return err
+	}
+	if hash != "" {
+		if hash, err = normalizeSHA256(hash); err != nil {
+			return err
+		}
+	}
+	return a.checksums.set(version, hash)
+}
+
+// GetMassaReleaseChecksum returns the SHA-256 the installer expects for version.
+func (a *App) GetMassaReleaseChecksum(version string) (*MassaReleaseChecksum, error) {
+	version, err := validMassaVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return a.resolveMassaChecksum(version)
+}
+
+// resolveMassaChecksum finds the expected hash of the x86_64 archive of
+// version, see resolveMassaArchiveChecksum.
+func (a *App) resolveMassaChecksum(version string) (*MassaReleaseChecksum, error) {
+	return a.resolveMassaArchiveChecksum(version, massaArchiveName(version))
+}
+
+// resolveMassaArchiveChecksum finds the expected hash of archive, an asset of
+// the release of version: a hash pinned by the user (x86_64 archive only),
+// then the manifest shipped with the manager, then the digest GitHub
+// publishes for the asset, then a checksum file attached to the release. It
+// fails if none of them has the archive.
+func (a *App) resolveMassaArchiveChecksum(version string, archive string) (*MassaReleaseChecksum, error) {
+	result := &MassaReleaseChecksum{Version: version, Archive: archive}
+
+	if archive == massaArchiveName(version) {
+		if hash, err := a.checksums.get(version); err != nil {
+			return nil, err
+		} else if hash != "" {
+			result.SHA256, result.Source = hash, checksumSourceUserPinned
+			return result, nil
+		}
+	}
+	if hash, ok := massaPinnedChecksums[archive]; ok {
+		result.SHA256, result.Source = hash, checksumSourcePinned
+		return result, nil
+	}
+
+	release, err := fetchGitHubRelease(version)
+	if err != nil {
+		return nil, fmt.Errorf("no pinned SHA-256 for Massa %s and the release could not be fetched: %w", version, err)
+	}
+	var checksumAssets []githubAsset
+	for _, asset := range release.Assets {
+		if asset.Name == archive && asset.Digest != "" {
+			if hash, err := normalizeSHA256(asset.Digest); err == nil {
+				result.SHA256, result.Source = hash, checksumSourceAssetDigest
+				return result, nil
+			}
+		}
+		if isChecksumAssetFor(asset.Name, archive) {
+			checksumAssets = append(checksumAssets, asset)
+		}
+	}
+	for _, asset := range checksumAssets {
+		hash, err := fetchChecksumFile(asset.BrowserDownloadURL, archive)
+		if err != nil {
+			fmt.Printf("Ignoring checksum file %s: %v\n", asset.Name, err)
+			continue
+		}
+		result.SHA256, result.Source = hash, checksumSourceChecksumFile
+		return result, nil
+	}
+	return nil, fmt.Errorf("no published SHA-256 found for %s; verify the archive yourself and pin its hash to install Massa %s", archive, version)
+}
+
+// isChecksumAssetFor reports whether the release asset name holds the hash of
+// archive: a checksum file named after it or one of massaChecksumManifests.
+func isChecksumAssetFor(name string, archive string) bool {
+	for _, suffix := range []string{".sha256", ".sha256sum", ".sha256.txt"} {
+		if name == archive+suffix {
+			return true
+		}
+	}
+	for _, manifest := range massaChecksumManifests {
+		if strings.EqualFold(name, manifest) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchChecksumFile downloads a checksum file and returns the hash listed for
+// archive. Only "sha256sum" lines ("<hash>  <file>") naming archive count; a
+// bare hash says nothing about which file it is for.
+func fetchChecksumFile(url string, archive string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 64*1024))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && path.Base(strings.TrimPrefix(fields[1], "*")) == archive {
+			return normalizeSHA256(fields[0])
+		}
+	}
+	return "", fmt.Errorf("%s is not listed", archive)
+}
+
+// GetMassaInstallHistory returns the releases installed on serverID, oldest
+// first. The history file has one "<time> <version> <sha256>" line per install,
+// followed by "unverified" for uploads that were not checked against a known
+// checksum. It lives outside the massa directory, so it survives reinstalls
+// and upgrades.
+func (a *App) GetMassaInstallHistory(serverID string) ([]InstalledMassaRelease, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	output, err := a.runReadOnlyCommand(serverID, "cat "+shellQuote(srv.getLayout().historyFile())+" 2>/dev/null || true")
+	if err != nil {
+		return nil, err
+	}
+	history := []InstalledMassaRelease{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 && (len(fields) != 4 || fields[3] != "unverified") {
+			continue
+		}
+		installedAt, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		history = append(history, InstalledMassaRelease{Version: fields[1], SHA256: fields[2], Verified: len(fields) == 3, InstalledAt: installedAt})
+	}
+	return history, nil
+}
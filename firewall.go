@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Firewall backends detected on a server.
+const (
+	firewallUFW       = "ufw"
+	firewallFirewalld = "firewalld"
+	firewallNftables  = "nftables"
+	firewallIptables  = "iptables"
+	firewallNone      = "none"
+)
+
+// firewallRuleComment tags the rules the manager adds, so they can be told
+// apart from the operator's own rules and removed again.
+const firewallRuleComment = "massa-node-manager"
+
+// FirewallPort is a node port and whether the firewall lets it through.
+type FirewallPort struct {
+	Port      int    `json:"port"`
+	Purpose   string `json:"purpose"`
+	Open      bool   `json:"open"`      // Allowed by the firewall (always true without one)
+	Listening bool   `json:"listening"` // Something listens on it on the server
+}
+
+// FirewallStatus reports the server's firewall as it affects the node.
+type FirewallStatus struct {
+	ServerID   string         `json:"serverId"`
+	Backend    string         `json:"backend"` // "ufw", "firewalld", "nftables", "iptables" or "none"
+	Active     bool           `json:"active"`
+	Persistent bool           `json:"persistent"` // Rules added by the manager survive a reboot
+	Ports      []FirewallPort `json:"ports"`
+	Rules      []string       `json:"rules"` // Current rules that mention the node's ports, plus default policies
+	Messages   []string       `json:"messages"`
+}
+
+// firewallStatusScript detects the active firewall and prints "key=value"
+// lines: the backend, the ports it allows among the arguments, the rules
+// mentioning them and the ports something listens on. For nftables the input
+// chain is printed too, since rules must go into the existing filter chain.
+const firewallStatusScript = `
+PORTS="$*"
+if command -v ufw > /dev/null 2>&1 && ufw status 2>/dev/null | grep -q "Status: active"; then
+    echo "backend=ufw"
+    ufw status 2>/dev/null | sed -n 's/^/rule=/p' | grep -E "rule=(Status|Default)" || true
+    for p in ${PORTS}; do
+        ufw status 2>/dev/null | grep -E "^${p}(/tcp)?[[:space:]]" | sed 's/^/rule=/'
+        ufw status 2>/dev/null | grep -qE "^${p}(/tcp)?[[:space:]]+ALLOW" && echo "open=${p}"
+    done
+elif command -v firewall-cmd > /dev/null 2>&1 && [ "$(firewall-cmd --state 2>/dev/null)" = "running" ]; then
+    echo "backend=firewalld"
+    echo "rule=zone $(firewall-cmd --get-default-zone 2>/dev/null): ports $(firewall-cmd --list-ports 2>/dev/null)"
+    for p in ${PORTS}; do
+        firewall-cmd --query-port="${p}/tcp" > /dev/null 2>&1 && echo "open=${p}"
+    done
+elif command -v nft > /dev/null 2>&1 && nft list ruleset 2>/dev/null | grep -q "hook input"; then
+    echo "backend=nftables"
+    nft list chains 2>/dev/null | awk '
+        /^table / { family = $2; table = $3 }
+        /^[[:space:]]*chain / { chain = $2 }
+        /hook input/ && !found { print "nft_chain=" family " " table " " chain; found = 1 }'
+    nft list ruleset 2>/dev/null | grep -E "hook input" | sed 's/^[[:space:]]*/rule=/'
+    for p in ${PORTS}; do
+        nft list ruleset 2>/dev/null | grep -E "dport (\{[^}]*[ ,])?${p}([ ,][^}]*\})? .*accept" | sed 's/^[[:space:]]*/rule=/'
+        nft list ruleset 2>/dev/null | grep -qE "dport (\{[^}]*[ ,])?${p}([ ,][^}]*\})? .*accept" && echo "open=${p}"
+    done
+elif command -v iptables > /dev/null 2>&1 && iptables -S INPUT 2>/dev/null | grep -qvE "^-P INPUT ACCEPT$"; then
+    echo "backend=iptables"
+    iptables -S INPUT 2>/dev/null | grep -E "^-P" | sed 's/^/rule=/'
+    for p in ${PORTS}; do
+        iptables -S INPUT 2>/dev/null | grep -E "dport ${p}( |$)" | sed 's/^/rule=/'
+        iptables -S INPUT 2>/dev/null | grep -E "dport ${p}( |$)" | grep -q "ACCEPT" && echo "open=${p}"
+    done
+    command -v netfilter-persistent > /dev/null 2>&1 && echo "persistent=1"
+else
+    echo "backend=none"
+fi
+if command -v ss > /dev/null 2>&1; then
+    ss -ltnH 2>/dev/null | awk '{print $4}' | sed 's/.*:/listen=/'
+fi
+true
+`
+
+// massaFirewallPorts returns the ports the node needs reachable from outside:
+// peer-to-peer and bootstrap, plus the public APIs when includeAPI is set.
+// Ports come from config.toml, falling back to the node's defaults. The
+// private API is never included.
+func (a *App) massaFirewallPorts(serverID string, includeAPI bool) ([]FirewallPort, error) {
+	file, _, err := a.readNodeConfig(serverID)
+	if err != nil {
+		return nil, err
+	}
+	cfg := file.Config
+	portOf := func(bind *string, fallback int) int {
+		if bind == nil {
+			return fallback
+		}
+		_, portStr, err := net.SplitHostPort(*bind)
+		if err != nil {
+			return fallback
+		}
+		if port, err := strconv.Atoi(portStr); err == nil {
+			return port
+		}
+		return fallback
+	}
+
+	var protocolBind, bootstrapBind, publicBind, apiBind *string
+	if cfg.Protocol != nil {
+		protocolBind = cfg.Protocol.Bind
+	}
+	if cfg.Bootstrap != nil {
+		bootstrapBind = cfg.Bootstrap.Bind
+	}
+	if cfg.API != nil {
+		publicBind, apiBind = cfg.API.BindPublic, cfg.API.BindAPI
+	}
+	ports := []FirewallPort{
+		{Port: portOf(protocolBind, 31244), Purpose: "protocol"},
+		{Port: portOf(bootstrapBind, 31245), Purpose: "bootstrap"},
+	}
+	if includeAPI {
+		ports = append(ports,
+			FirewallPort{Port: portOf(publicBind, massaPublicAPIPort), Purpose: "public API"},
+			FirewallPort{Port: portOf(apiBind, 33036), Purpose: "API"},
+		)
+	}
+	return ports, nil
+}
+
+// GetFirewallStatus detects the server's firewall and reports whether it lets
+// the node's ports through. includeAPI adds the public API ports to the report.
+func (a *App) GetFirewallStatus(serverID string, includeAPI bool) (*FirewallStatus, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	ports, err := a.massaFirewallPorts(serverID, includeAPI)
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := a.firewallStatus(srv, ports)
+	return status, err
+}
+
+// firewallStatus runs firewallStatusScript for ports. It also returns the
+// nftables input chain ("family table chain"), if any.
+func (a *App) firewallStatus(srv *serverConn, ports []FirewallPort) (*FirewallStatus, string, error) {
+	argv := []string{"sh", "-c", firewallStatusScript, "sh"}
+	for _, p := range ports {
+		argv = append(argv, strconv.Itoa(p.Port))
+	}
+	output, err := a.runReadOnlyCommand(srv.id, srv.asRoot(shellJoin(argv...)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to inspect the firewall: %w", err)
+	}
+
+	status := &FirewallStatus{ServerID: srv.id, Backend: firewallNone, Ports: ports, Rules: []string{}, Messages: []string{}}
+	open := map[int]bool{}
+	listening := map[int]bool{}
+	nftChain := ""
+	seenRules := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "backend":
+			status.Backend = value
+		case "open":
+			port, _ := strconv.Atoi(value)
+			open[port] = true
+		case "listen":
+			if port, err := strconv.Atoi(value); err == nil {
+				listening[port] = true
+			}
+		case "rule":
+			if value != "" && !seenRules[value] {
+				seenRules[value] = true
+				status.Rules = append(status.Rules, value)
+			}
+		case "nft_chain":
+			nftChain = value
+		case "persistent":
+			status.Persistent = true
+		}
+	}
+
+	status.Active = status.Backend != firewallNone
+	switch status.Backend {
+	case firewallUFW, firewallFirewalld:
+		status.Persistent = true
+	case firewallNftables:
+		status.Messages = append(status.Messages, "nftables rules added by the manager last until the next reboot unless the ruleset is saved")
+	case firewallIptables:
+		if !status.Persistent {
+			status.Messages = append(status.Messages, "iptables rules added by the manager last until the next reboot (netfilter-persistent is not installed)")
+		}
+	case firewallNone:
+		status.Messages = append(status.Messages, "No active firewall found on the server; node ports are not filtered there (a provider firewall may still apply)")
+	}
+	for i := range status.Ports {
+		status.Ports[i].Open = !status.Active || open[status.Ports[i].Port]
+		status.Ports[i].Listening = listening[status.Ports[i].Port]
+	}
+	return status, nftChain, nil
+}
+
+// OpenMassaPorts allows the node's ports through the server's firewall and
+// returns the status afterwards. includeAPI also opens the public API ports.
+func (a *App) OpenMassaPorts(serverID string, includeAPI bool) (*FirewallStatus, error) {
+	fmt.Println("OpenMassaPorts called")
+	return a.changeMassaPorts(serverID, includeAPI, true)
+}
+
+// CloseMassaPorts removes the rules OpenMassaPorts added and returns the
+// status afterwards. Rules the operator added by hand are left alone, except
+// with ufw and firewalld, which do not tell them apart.
+func (a *App) CloseMassaPorts(serverID string, includeAPI bool) (*FirewallStatus, error) {
+	fmt.Println("CloseMassaPorts called")
+	return a.changeMassaPorts(serverID, includeAPI, false)
+}
+
+func (a *App) changeMassaPorts(serverID string, includeAPI bool, open bool) (*FirewallStatus, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	ports, err := a.massaFirewallPorts(serverID, includeAPI)
+	if err != nil {
+		return nil, err
+	}
+	before, nftChain, err := a.firewallStatus(srv, ports)
+	if err != nil {
+		return nil, err
+	}
+	if !before.Active {
+		return before, nil
+	}
+
+	var commands []string
+	for _, p := range before.Ports {
+		if p.Open == open {
+			continue
+		}
+		port := strconv.Itoa(p.Port)
+		switch before.Backend {
+		case firewallUFW:
+			if open {
+				commands = append(commands, shellJoin("ufw", "allow", port+"/tcp", "comment", firewallRuleComment))
+			} else {
+				commands = append(commands, shellJoin("ufw", "delete", "allow", port+"/tcp"))
+			}
+		case firewallFirewalld:
+			action := "--add-port=" + port + "/tcp"
+			if !open {
+				action = "--remove-port=" + port + "/tcp"
+			}
+			commands = append(commands, shellJoin("firewall-cmd", "--permanent", action))
+		case firewallNftables:
+			chain := strings.Fields(nftChain)
+			if len(chain) != 3 {
+				return before, fmt.Errorf("could not find the nftables input chain")
+			}
+			if open {
+				commands = append(commands, shellJoin(append([]string{"nft", "insert", "rule"}, append(chain,
+					"tcp", "dport", port, "accept", "comment", `"`+firewallRuleComment+`"`)...)...))
+			} else {
+				// Delete the manager's rules for this port by handle.
+				list := shellJoin(append([]string{"nft", "-a", "list", "chain"}, chain...)...)
+				del := shellJoin(append([]string{"nft", "delete", "rule"}, chain...)...)
+				commands = append(commands, fmt.Sprintf(`%s | grep -E 'dport %s .*comment "%s"' | sed -n 's/.*# handle \([0-9]*\)$/\1/p' | while read -r h; do %s handle "$h"; done`,
+					list, port, firewallRuleComment, del))
+			}
+		case firewallIptables:
+			// Check with -C first so opening does not add duplicates and
+			// closing succeeds when a family has no rule for the port (or
+			// removes every copy when it has several).
+			spec := []string{"INPUT", "-p", "tcp", "--dport", port, "-m", "comment", "--comment", firewallRuleComment, "-j", "ACCEPT"}
+			for _, tool := range []string{"iptables", "ip6tables"} {
+				check := shellJoin(append([]string{tool, "-C"}, spec...)...) + " 2>/dev/null"
+				rule := "{ " + check + " || " + shellJoin(append([]string{tool, "-I"}, spec...)...) + "; }"
+				if !open {
+					rule = "while " + check + "; do " + shellJoin(append([]string{tool, "-D"}, spec...)...) + " || break; done"
+				}
+				if tool == "ip6tables" {
+					rule = "if command -v ip6tables > /dev/null 2>&1; then " + rule + "; fi"
+				}
+				commands = append(commands, rule)
+			}
+		}
+	}
+	if len(commands) == 0 {
+		return before, nil
+	}
+	switch {
+	case before.Backend == firewallFirewalld:
+		commands = append(commands, "firewall-cmd --reload")
+	case before.Backend == firewallIptables && before.Persistent:
+		commands = append(commands, "netfilter-persistent save")
+	}
+
+	output, err := a.RunCommand(serverID, srv.asRoot(strings.Join(commands, " && ")))
+	if err != nil {
+		return before, fmt.Errorf("failed to update %s rules: %w: %s", before.Backend, err, lastLines(output, 5))
+	}
+
+	after, _, err := a.firewallStatus(srv, ports)
+	if err != nil {
+		return nil, err
+	}
+	var wrong []string
+	for _, p := range after.Ports {
+		if p.Open != open {
+			wrong = append(wrong, strconv.Itoa(p.Port))
+		}
+	}
+	if len(wrong) > 0 {
+		sort.Strings(wrong)
+		state := "open"
+		if !open {
+			state = "closed"
+		}
+		return after, fmt.Errorf("ports %s are still not %s after updating %s", strings.Join(wrong, ", "), state, after.Backend)
+	}
+	return after, nil
+}
@@ -11,7 +11,10 @@ import {
   CheckMassaNodeStatus as BackendCheckMassaNodeStatus,
   StartMassaNode as BackendStartMassaNode,
   GetMassaNodeLogs as BackendGetMassaNodeLogs,
+  ConfirmHostKey,
+  AnswerAuthPrompt,
 } from "../wailsjs/go/main/App";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 
 // Import components
 import WelcomeScreen from "./components/WelcomeScreen";
@@ -31,6 +34,8 @@ function App() {
   const [connectionStatus, setConnectionStatus] = useState("");
   const [isConnecting, setIsConnecting] = useState(false);
   const [isConnected, setIsConnected] = useState(false);
+  // The backend keeps one connection per server ID
+  const serverId = `${user}@${host}:${port}`;
 
   // Command Execution State
   const [command, setCommand] = useState("ls -la");
@@ -74,7 +79,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors when the backend functions don't exist yet
       const { GetWalletInfo } = await import("../wailsjs/go/main/App");
-      return await GetWalletInfo();
+      return await GetWalletInfo(serverId);
     } catch (error: any) {
       console.error("Error getting wallet info:", error);
       toast.error(`Failed to get wallet info: ${error?.message || error}`);
@@ -92,7 +97,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors
       const { GenerateWalletKey } = await import("../wailsjs/go/main/App");
-      return await GenerateWalletKey();
+      return await GenerateWalletKey(serverId);
     } catch (error: any) {
       console.error("Error generating wallet key:", error);
       toast.error(`Failed to generate wallet key: ${error?.message || error}`);
@@ -114,7 +119,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors
       const { ImportWalletKey } = await import("../wailsjs/go/main/App");
-      return await ImportWalletKey(secretKey);
+      return await ImportWalletKey(serverId, secretKey);
     } catch (error: any) {
       console.error("Error importing wallet key:", error);
       toast.error(`Failed to import wallet key: ${error?.message || error}`);
@@ -140,7 +145,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors
       const { BuyRolls } = await import("../wailsjs/go/main/App");
-      return await BuyRolls(address, rollCount, fee);
+      return await BuyRolls(serverId, address, rollCount, fee);
     } catch (error: any) {
       console.error("Error buying rolls:", error);
       toast.error(`Failed to buy rolls: ${error?.message || error}`);
@@ -166,7 +171,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors
       const { SellRolls } = await import("../wailsjs/go/main/App");
-      return await SellRolls(address, rollCount, fee);
+      return await SellRolls(serverId, address, rollCount, fee);
     } catch (error: any) {
       console.error("Error selling rolls:", error);
       toast.error(`Failed to sell rolls: ${error?.message || error}`);
@@ -188,7 +193,7 @@ function App() {
     try {
       // Import dynamically to avoid linter errors
       const { StartStaking } = await import("../wailsjs/go/main/App");
-      return await StartStaking(address);
+      return await StartStaking(serverId, address);
     } catch (error: any) {
       console.error("Error starting staking:", error);
       toast.error(`Failed to start staking: ${error?.message || error}`);
@@ -207,7 +212,7 @@ function App() {
     }
     setIsLoadingServerStats(true);
     try {
-      const result = await BackendGetServerStats();
+      const result = await BackendGetServerStats(serverId);
       // Initialize with N/A to clear previous values if parsing fails for any part
       let parsedCpu = "N/A";
       let parsedRam = "N/A";
@@ -260,7 +265,7 @@ function App() {
     if (!isConnected) return;
     setIsCheckingNodeStatus(true);
     try {
-      const status = await BackendCheckMassaNodeStatus();
+      const status = await BackendCheckMassaNodeStatus(serverId);
       setMassaNodeStatus(status);
     } catch (error: any) {
       setMassaNodeStatus("Error fetching status");
@@ -286,6 +291,7 @@ function App() {
     setForceReinstall(false);
     try {
       const connResult = await ConnectToServer(
+        serverId,
         host,
         Number(port),
         user,
@@ -299,7 +305,7 @@ function App() {
           (prev) => prev + "Checking for existing Massa Node installation...\n"
         );
         try {
-          const checkResult = await BackendCheckMassaNodeInstallation();
+          const checkResult = await BackendCheckMassaNodeInstallation(serverId);
           setSetupLog((prev) => prev + checkResult + "\n");
           if (checkResult === "INSTALLED") {
             setInstallationSuccess(true);
@@ -365,7 +371,7 @@ function App() {
     const disconnectToastId = toast.loading("Disconnecting from server...");
 
     try {
-      const result = await DisconnectFromServer();
+      const result = await DisconnectFromServer(serverId);
       setConnectionStatus(result);
       toast.success("Disconnected from server.", { id: disconnectToastId });
     } catch (error: any) {
@@ -402,7 +408,7 @@ function App() {
     setIsRunningCommand(true);
     const cmdToastId = toast.loading(`Running: ${command}`);
     try {
-      const result = await RunCommand(command);
+      const result = await RunCommand(serverId, command);
       setCommandOutput(result);
       toast.success(`Command finished. Output below.`, { id: cmdToastId });
     } catch (error: any) {
@@ -440,6 +446,7 @@ function App() {
     setInstallationSuccess(false);
     try {
       const result = await BackendSetupAndRunMassaComponents(
+        serverId,
         nodePassword,
         publicIp,
         forceReinstall
@@ -524,7 +531,7 @@ function App() {
 
     try {
       console.log("Calling BackendStartMassaNode...");
-      const result = await BackendStartMassaNode(nodePassword);
+      const result = await BackendStartMassaNode(serverId, nodePassword);
       console.log("BackendStartMassaNode result:", result);
       setSetupLog(
         (prev) => prev + "\n--- Start Node Operation ---\n" + result + "\n"
@@ -568,7 +575,7 @@ function App() {
 
     setIsLoadingNodeLogs(true);
     try {
-      const logs = await BackendGetMassaNodeLogs();
+      const logs = await BackendGetMassaNodeLogs(serverId);
       setNodeLogs(logs);
       return logs;
     } catch (error: any) {
@@ -595,6 +602,54 @@ function App() {
     }
   }, [isConnected]); // Rerun when isConnected changes
 
+  // Trust-on-first-use: the backend asks us to confirm unknown host keys
+  useEffect(() => {
+    return EventsOn("hostkey:confirm", (prompt: any) => {
+      const accept = window.confirm(
+        `The authenticity of host ${prompt.host} can't be established.\n\n` +
+          `${prompt.keyType} key fingerprint is:\n${prompt.fingerprint}\n\n` +
+          "Only continue if this matches the fingerprint of your server. Trust this host?"
+      );
+      ConfirmHostKey(prompt.id, accept).catch((error: any) =>
+        toast.error(`Host key confirmation failed: ${error?.message || error}`)
+      );
+    });
+  }, []);
+
+  // Keyboard-interactive auth (e.g. 2FA codes) the backend could not answer itself
+  useEffect(() => {
+    return EventsOn("auth:prompt", (prompt: any) => {
+      const answers: string[] = [];
+      for (const question of prompt.questions || []) {
+        const answer = window.prompt(
+          [prompt.instruction, question.text].filter(Boolean).join("\n")
+        );
+        if (answer === null) {
+          AnswerAuthPrompt(prompt.id, null as any);
+          return;
+        }
+        answers.push(answer);
+      }
+      AnswerAuthPrompt(prompt.id, answers).catch((error: any) =>
+        toast.error(`Authentication prompt failed: ${error?.message || error}`)
+      );
+    });
+  }, []);
+
+  // Connection supervisor: the backend reconnects on its own when the link drops
+  useEffect(() => {
+    return EventsOn("server:state", (state: any) => {
+      if (state.serverId !== serverId) return;
+      if (state.state === "reconnecting") {
+        toast.loading(`Connection lost, reconnecting (attempt ${state.attempt || 1})...`, {
+          id: "server-state",
+        });
+      } else if (state.state === "connected") {
+        toast.success("Reconnected to server.", { id: "server-state" });
+      }
+    });
+  }, [serverId]);
+
   // Render current view
   return (
     <div className="bg-gray-900 min-h-screen">
@@ -2,74 +2,82 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function BuyRolls(arg1, arg2, arg3) {
-  return window['go']['main']['App']['BuyRolls'](arg1, arg2, arg3);
+export function AnswerAuthPrompt(arg1, arg2) {
+  return window['go']['main']['App']['AnswerAuthPrompt'](arg1, arg2);
 }
 
-export function CheckMassaNodeInstallation() {
-  return window['go']['main']['App']['CheckMassaNodeInstallation']();
+export function BuyRolls(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['BuyRolls'](arg1, arg2, arg3, arg4);
 }
 
-export function CheckMassaNodeStatus() {
-  return window['go']['main']['App']['CheckMassaNodeStatus']();
+export function CheckMassaNodeInstallation(arg1) {
+  return window['go']['main']['App']['CheckMassaNodeInstallation'](arg1);
 }
 
-export function ConnectToServer(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['ConnectToServer'](arg1, arg2, arg3, arg4);
+export function CheckMassaNodeStatus(arg1) {
+  return window['go']['main']['App']['CheckMassaNodeStatus'](arg1);
 }
 
-export function DisconnectFromServer() {
-  return window['go']['main']['App']['DisconnectFromServer']();
+export function ConfirmHostKey(arg1, arg2) {
+  return window['go']['main']['App']['ConfirmHostKey'](arg1, arg2);
 }
 
-export function GenerateWalletKey() {
-  return window['go']['main']['App']['GenerateWalletKey']();
+export function ConnectToServer(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['ConnectToServer'](arg1, arg2, arg3, arg4, arg5);
 }
 
-export function GetAddressPublicKey(arg1) {
-  return window['go']['main']['App']['GetAddressPublicKey'](arg1);
+export function DisconnectFromServer(arg1) {
+  return window['go']['main']['App']['DisconnectFromServer'](arg1);
 }
 
-export function GetMassaNodeLogs() {
-  return window['go']['main']['App']['GetMassaNodeLogs']();
+export function GenerateWalletKey(arg1) {
+  return window['go']['main']['App']['GenerateWalletKey'](arg1);
 }
 
-export function GetServerStats() {
-  return window['go']['main']['App']['GetServerStats']();
+export function GetAddressPublicKey(arg1, arg2) {
+  return window['go']['main']['App']['GetAddressPublicKey'](arg1, arg2);
 }
 
-export function GetWalletInfo() {
-  return window['go']['main']['App']['GetWalletInfo']();
+export function GetMassaNodeLogs(arg1) {
+  return window['go']['main']['App']['GetMassaNodeLogs'](arg1);
+}
+
+export function GetServerStats(arg1) {
+  return window['go']['main']['App']['GetServerStats'](arg1);
+}
+
+export function GetWalletInfo(arg1) {
+  return window['go']['main']['App']['GetWalletInfo'](arg1);
 }
 
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
-export function ImportWalletKey(arg1) {
-  return window['go']['main']['App']['ImportWalletKey'](arg1);
+export function ImportWalletKey(arg1, arg2) {
+  return window['go']['main']['App']['ImportWalletKey'](arg1, arg2);
 }
 
-export function RunCommand(arg1) {
-  return window['go']['main']['App']['RunCommand'](arg1);
+export function RunCommand(arg1, arg2) {
+  return window['go']['main']['App']['RunCommand'](arg1, arg2);
 }
 
-export function RunMassaClientCommand(arg1) {
-  return window['go']['main']['App']['RunMassaClientCommand'](arg1);
+export function RunMassaClientCommand(arg1, arg2) {
+  return window['go']['main']['App']['RunMassaClientCommand'](arg1, arg2);
 }
 
-export function SellRolls(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SellRolls'](arg1, arg2, arg3);
+export function SellRolls(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SellRolls'](arg1, arg2, arg3, arg4);
 }
 
-export function SetupAndRunMassaComponents(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SetupAndRunMassaComponents'](arg1, arg2, arg3);
+export function SetupAndRunMassaComponents(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['SetupAndRunMassaComponents'](arg1, arg2, arg3, arg4);
 }
 
-export function StartMassaNode(arg1) {
-  return window['go']['main']['App']['StartMassaNode'](arg1);
+export function StartMassaNode(arg1, arg2) {
+  return window['go']['main']['App']['StartMassaNode'](arg1, arg2);
 }
 
-export function StartStaking(arg1) {
-  return window['go']['main']['App']['StartStaking'](arg1);
+export function StartStaking(arg1, arg2) {
+  return window['go']['main']['App']['StartStaking'](arg1, arg2);
 }
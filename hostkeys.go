@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHost describes a trusted host key stored in the manager's known_hosts file.
+type KnownHost struct {
+	Host        string `json:"host"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+}
+
+// HostKeyPrompt is sent to the frontend (event "hostkey:confirm") the first time
+// we see a server, so the user can compare the fingerprint before trusting it.
+type HostKeyPrompt struct {
+	ID          string `json:"id"`
+	Host        string `json:"host"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// HostKeyChangedError is returned when a server presents a key that does not
+// match the one we trusted earlier. This is a hard failure: the connection is
+// refused until the user revokes the old key on purpose.
+type HostKeyChangedError struct {
+	Host                 string
+	PresentedKeyType     string
+	PresentedFingerprint string
+	Known                []KnownHost
+}
+
+func (e *HostKeyChangedError) Error() string {
+	var known []string
+	for _, k := range e.Known {
+		known = append(known, fmt.Sprintf("%s %s", k.KeyType, k.Fingerprint))
+	}
+	return fmt.Sprintf("host key changed for %s: server presented %s %s but we trust %s. "+
+		"This could mean someone is intercepting the connection. If the server was reinstalled on purpose, revoke the old key and connect again",
+		e.Host, e.PresentedKeyType, e.PresentedFingerprint, strings.Join(known, ", "))
+}
+
+// hostKeyStore keeps trusted host keys in an OpenSSH-compatible known_hosts file
+// inside the manager's config directory.
+type hostKeyStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newHostKeyStore(path string) *hostKeyStore {
+	return &hostKeyStore{path: path}
+}
+
+// ensureFile creates the known_hosts file (and its directory) if it does not exist yet,
+// since knownhosts.New refuses to open a missing file.
+func (s *hostKeyStore) ensureFile() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create known_hosts file: %w", err)
+	}
+	return f.Close()
+}
+
+// check verifies key against the stored entries using the knownhosts package.
+func (s *hostKeyStore) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureFile(); err != nil {
+		return err
+	}
+	callback, err := knownhosts.New(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+	return callback(hostname, remote, key)
+}
+
+// add appends a trusted key for address ("host:port").
+func (s *hostKeyStore) add(address string, key ssh.PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureFile(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known_hosts for writing: %w", err)
+	}
+	defer f.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, key)
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("failed to write known_hosts entry: %w", err)
+	}
+	return nil
+}
+
+// list returns every key in the store. Hashed or otherwise unparsable lines are skipped.
+func (s *hostKeyStore) list() ([]KnownHost, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []KnownHost{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	hosts := []KnownHost{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		_, patterns, key, _, _, err := ssh.ParseKnownHosts(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		for _, pattern := range patterns {
+			hosts = append(hosts, knownHostFromKey(pattern, key))
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// remove rewrites the store without any entry for address and reports how many were dropped.
+func (s *hostKeyStore) remove(address string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	target := knownhosts.Normalize(address)
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		_, patterns, _, _, _, err := ssh.ParseKnownHosts(line)
+		if err == nil && containsString(patterns, target) {
+			removed++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := os.WriteFile(s.path, kept.Bytes(), 0600); err != nil {
+		return 0, fmt.Errorf("failed to rewrite known_hosts: %w", err)
+	}
+	return removed, nil
+}
+
+// algorithms returns the key types already trusted for address, so the SSH
+// handshake asks for a key we can actually verify instead of tripping a
+// mismatch because the server offered a different key type first.
+func (s *hostKeyStore) algorithms(address string) []string {
+	hosts, err := s.list()
+	if err != nil {
+		return nil
+	}
+	target := knownhosts.Normalize(address)
+	var algos []string
+	for _, h := range hosts {
+		if h.Host != target {
+			continue
+		}
+		algos = append(algos, hostKeyAlgorithmsFor(h.KeyType)...)
+	}
+	return algos
+}
+
+// hostKeyAlgorithmsFor maps a stored key type to the handshake algorithms that produce it.
+// RSA keys can be negotiated with SHA-2 signatures, which OpenSSH servers prefer.
+func hostKeyAlgorithmsFor(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+func knownHostFromKey(host string, key ssh.PublicKey) KnownHost {
+	return KnownHost{
+		Host:        host,
+		KeyType:     key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyHostKey is the ssh.HostKeyCallback used for every connection. Known keys
+// are accepted, changed keys are rejected, and unknown hosts are trusted on first
+// use only after the user confirms the fingerprint in the frontend.
+func (a *App) verifyHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := a.hostKeys.check(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	if len(keyErr.Want) > 0 {
+		changed := &HostKeyChangedError{
+			Host:                 knownhosts.Normalize(hostname),
+			PresentedKeyType:     key.Type(),
+			PresentedFingerprint: ssh.FingerprintSHA256(key),
+		}
+		for _, want := range keyErr.Want {
+			changed.Known = append(changed.Known, knownHostFromKey(changed.Host, want.Key))
+		}
+		fmt.Println(changed.Error())
+		return changed
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	fmt.Printf("Unknown host key for %s (%s %s), asking user to confirm\n", hostname, key.Type(), fingerprint)
+	reply, err := a.askFrontend("hostkey:confirm", func(id string) interface{} {
+		return HostKeyPrompt{
+			ID:          id,
+			Host:        knownhosts.Normalize(hostname),
+			KeyType:     key.Type(),
+			Fingerprint: fingerprint,
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("host key for %s was not confirmed: %w", hostname, err)
+	}
+	if !reply.Accept {
+		return fmt.Errorf("host key for %s was rejected by the user", hostname)
+	}
+
+	if err := a.hostKeys.add(hostname, key); err != nil {
+		return err
+	}
+	fmt.Printf("Trusted new host key for %s: %s\n", hostname, fingerprint)
+	return nil
+}
+
+// verifyKnownHostKey is the ssh.HostKeyCallback used for background reconnects:
+// only keys already trusted are accepted and the user is never asked.
+func (a *App) verifyKnownHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if err := a.hostKeys.check(hostname, remote, key); err != nil {
+		return fmt.Errorf("host key for %s is not trusted, reconnect manually: %w", hostname, err)
+	}
+	return nil
+}
+
+// ConfirmHostKey answers a "hostkey:confirm" prompt emitted during ConnectToServer.
+func (a *App) ConfirmHostKey(promptID string, accept bool) error {
+	return a.prompts.resolve(promptID, promptReply{Accept: accept})
+}
+
+// ListKnownHosts returns every host key the manager currently trusts.
+func (a *App) ListKnownHosts() ([]KnownHost, error) {
+	return a.hostKeys.list()
+}
+
+// PinHostKey trusts publicKey (authorized_keys format, e.g. "ssh-ed25519 AAAA...")
+// for host:port ahead of the first connection, so no confirmation prompt is needed.
+func (a *App) PinHostKey(host string, port int, publicKey string) (string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return "Error: Invalid public key.", fmt.Errorf("invalid public key: %w", err)
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if err := a.hostKeys.add(address, key); err != nil {
+		return fmt.Sprintf("Error pinning host key: %v", err), err
+	}
+	return fmt.Sprintf("Pinned %s key %s for %s.", key.Type(), ssh.FingerprintSHA256(key), knownhosts.Normalize(address)), nil
+}
+
+// RevokeHostKey forgets every trusted key for host:port. The next connection will
+// go through the first-use confirmation again.
+func (a *App) RevokeHostKey(host string, port int) (string, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	removed, err := a.hostKeys.remove(address)
+	if err != nil {
+		return fmt.Sprintf("Error revoking host key: %v", err), err
+	}
+	if removed == 0 {
+		return fmt.Sprintf("No trusted host key found for %s.", knownhosts.Normalize(address)), nil
+	}
+	return fmt.Sprintf("Revoked %d host key(s) for %s.", removed, knownhosts.Normalize(address)), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// defaultInstallDir is the install base directory used when a server has none configured.
+const defaultInstallDir = "/root/massa_node"
+
+// runAsUserPattern matches the account names useradd accepts by default.
+var runAsUserPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// NodeLayout says where the node lives on a server and which account runs it.
+type NodeLayout struct {
+	InstallDir string `json:"installDir"` // Base directory holding massa/, the runner and the install history
+	RunAsUser  string `json:"runAsUser"`  // Account running the node and client, "" for the SSH user
+	DataDir    string `json:"dataDir"`    // Node storage (ledger, peers), "" to keep it inside the install directory
+}
+
+// installPathPattern lists the characters allowed in install and data
+// directories.
+var installPathPattern = regexp.MustCompile(`^/[A-Za-z0-9._/-]*$`)
+
+// systemDirs are directories whose whole tree belongs to the system. Install
+// and data directories are chowned recursively to the run-as user and may be
+// deleted on reinstall, so they must not be or sit inside any of these.
+var systemDirs = []string{"/bin", "/boot", "/dev", "/etc", "/lib", "/lib32", "/lib64", "/libx32", "/proc",
+	"/run", "/sbin", "/sys", "/tmp", "/usr", "/var/tmp", "/var/log", "/var/cache", "/var/spool", "/var/run", "/var/lock"}
+
+// sharedDirs are directories that may hold an install or data directory but
+// must not be one.
+var sharedDirs = []string{"/var/lib", "/var/opt", "/home", "/root", "/opt", "/srv", "/mnt", "/media"}
+
+// isSubdir reports whether dir is parent or inside it. Both must be clean.
+func isSubdir(dir string, parent string) bool {
+	return dir == parent || strings.HasPrefix(dir, parent+"/")
+}
+
+// validInstallPath checks a directory that ends up in shell scripts, in
+// single-quoted strings inside screen commands and in the systemd unit. Only
+// absolute paths of letters, digits, ".", "_", "-" and "/" are accepted, and
+// neither top-level nor system directories.
+func validInstallPath(name string, dir string) (string, error) {
+	if !path.IsAbs(dir) {
+		return "", fmt.Errorf("%s must be an absolute path", name)
+	}
+	if !installPathPattern.MatchString(dir) {
+		return "", fmt.Errorf("%s may only contain letters, digits, '.', '_', '-' and '/'", name)
+	}
+	for _, part := range strings.Split(dir, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%s must not contain '..'", name)
+		}
+	}
+	dir = path.Clean(dir)
+	if path.Dir(dir) == "/" {
+		return "", fmt.Errorf("%s must not be a top-level directory", name)
+	}
+	for _, system := range systemDirs {
+		if isSubdir(dir, system) {
+			return "", fmt.Errorf("%s must not be inside %s", name, system)
+		}
+	}
+	for _, shared := range sharedDirs {
+		if dir == shared {
+			return "", fmt.Errorf("%s must be a directory inside %s, not %s itself", name, shared, shared)
+		}
+	}
+	return dir, nil
+}
+
+// validNodeLayout normalises l, filling in the defaults.
+func validNodeLayout(l NodeLayout) (NodeLayout, error) {
+	var err error
+	if strings.TrimSpace(l.InstallDir) == "" {
+		l.InstallDir = defaultInstallDir
+	}
+	if l.InstallDir, err = validInstallPath("install directory", strings.TrimSpace(l.InstallDir)); err != nil {
+		return l, err
+	}
+	l.RunAsUser = strings.TrimSpace(l.RunAsUser)
+	if l.RunAsUser != "" && !runAsUserPattern.MatchString(l.RunAsUser) {
+		return l, fmt.Errorf("invalid user name %q", l.RunAsUser)
+	}
+	l.DataDir = strings.TrimSpace(l.DataDir)
+	if l.DataDir != "" {
+		if l.DataDir, err = validInstallPath("data directory", l.DataDir); err != nil {
+			return l, err
+		}
+		if isSubdir(l.DataDir, l.InstallDir) || isSubdir(l.InstallDir, l.DataDir) {
+			return l, fmt.Errorf("data directory and install directory must not contain one another")
+		}
+	}
+	return l, nil
+}
+
+// massaDir is the directory the release archive unpacks to.
+func (l NodeLayout) massaDir() string { return path.Join(l.InstallDir, "massa") }
+
+func (l NodeLayout) nodeDir() string { return path.Join(l.massaDir(), "massa-node") }
+
+func (l NodeLayout) clientDir() string { return path.Join(l.massaDir(), "massa-client") }
+
+func (l NodeLayout) nodeLogPath() string { return path.Join(l.nodeDir(), "logs.txt") }
+
+// runnerPath is where massaRunnerScript is installed. It lives outside the
+// massa directory so a reinstall does not remove it.
+func (l NodeLayout) runnerPath() string { return path.Join(l.InstallDir, "massa-run.sh") }
+
+// versionFile records the installed release, see GetInstalledMassaVersion.
+func (l NodeLayout) versionFile() string { return path.Join(l.massaDir(), "VERSION") }
+
+// historyFile lists every release installed, see GetMassaInstallHistory.
+func (l NodeLayout) historyFile() string { return path.Join(l.InstallDir, "releases.log") }
+
+func (l NodeLayout) setupScriptPath() string {
+	return path.Join(l.InstallDir, "setup_massa_services.sh")
+}
+
+// SetNodeLayout sets the install directory, run-as user and data directory of
+// serverID. It does not touch the server; run SetupAndRunMassaComponents to
+// install with the new layout.
+func (a *App) SetNodeLayout(serverID string, layout NodeLayout) (*NodeLayout, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	layout, err = validNodeLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	srv.setLayout(layout)
+	return &layout, nil
+}
+
+// GetNodeLayout returns the layout used for serverID.
+func (a *App) GetNodeLayout(serverID string) (*NodeLayout, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	layout := srv.getLayout()
+	return &layout, nil
+}
+
+// chownToNodeUser gives the install and data directories to the run-as user,
+// if one is configured.
+func (a *App) chownToNodeUser(serverID string) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	layout := srv.getLayout()
+	if layout.RunAsUser == "" {
+		return nil
+	}
+	argv := []string{"chown", "-R", layout.RunAsUser + ":", layout.InstallDir}
+	if layout.DataDir != "" {
+		argv = append(argv, layout.DataDir)
+	}
+	if _, err := a.runArgs(serverID, argv, nil); err != nil {
+		return fmt.Errorf("failed to give %s to %s: %w", layout.InstallDir, layout.RunAsUser, err)
+	}
+	return nil
+}
+
+// asRoot wraps command so it runs as root: unchanged for a root SSH user,
+// through sudo without a prompt otherwise.
+func (s *serverConn) asRoot(command string) string {
+	if s.user == "root" {
+		return command
+	}
+	return shellJoin("sudo", "-n", "/bin/sh", "-c", command)
+}
+
+// asNodeUser wraps command so it runs as the node's run-as user. Without one,
+// or when the SSH user is that account already, command is returned as is.
+// runuser needs root; any other SSH user goes through sudo without a prompt.
+func (s *serverConn) asNodeUser(command string) string {
+	user := s.getLayout().RunAsUser
+	if user == "" || user == s.user {
+		return command
+	}
+	if s.user == "root" {
+		return shellJoin("runuser", "-u", user, "--", "/bin/bash", "-c", command)
+	}
+	return shellJoin("sudo", "-n", "-H", "-u", user, "--", "/bin/bash", "-c", command)
+}
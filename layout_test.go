@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidNodeLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  NodeLayout
+		wantErr string // Substring of the error, "" for a valid layout
+	}{
+		{"default", NodeLayout{}, ""},
+		{"custom", NodeLayout{InstallDir: "/opt/massa", RunAsUser: "massa", DataDir: "/var/lib/massa-data"}, ""},
+		{"separate trees", NodeLayout{InstallDir: "/home/massa/node", DataDir: "/srv/massa/storage"}, ""},
+		{"relative", NodeLayout{InstallDir: "massa"}, "absolute"},
+		{"special characters", NodeLayout{InstallDir: "/opt/massa node"}, "may only contain"},
+		{"dot dot", NodeLayout{InstallDir: "/opt/massa/../../etc/x"}, "'..'"},
+		{"root", NodeLayout{InstallDir: "/"}, "top-level"},
+		{"top level", NodeLayout{InstallDir: "/massa"}, "top-level"},
+		{"shared directory", NodeLayout{DataDir: "/home"}, "top-level"},
+		{"var lib", NodeLayout{DataDir: "/var/lib"}, "not /var/lib itself"},
+		{"system tree", NodeLayout{InstallDir: "/usr/local/massa"}, "inside /usr"},
+		{"etc", NodeLayout{DataDir: "/etc/massa"}, "inside /etc"},
+		{"data dir is an ancestor", NodeLayout{InstallDir: "/root/massa_node", DataDir: "/root"}, "top-level"},
+		{"data dir contains install dir", NodeLayout{InstallDir: "/srv/massa/node", DataDir: "/srv/massa"}, "contain one another"},
+		{"data dir inside install dir", NodeLayout{InstallDir: "/srv/massa", DataDir: "/srv/massa/storage"}, "contain one another"},
+		{"data dir is install dir", NodeLayout{InstallDir: "/srv/massa", DataDir: "/srv/massa/"}, "contain one another"},
+		{"bad user", NodeLayout{RunAsUser: "Massa User"}, "invalid user name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validNodeLayout(tt.layout)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("no error, want one containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServiceJournalRunsAsRoot(t *testing.T) {
+	a := &App{servers: make(map[string]*serverConn)}
+	srv := newServerConn("s", "example.org", 22, "deploy", AuthOptions{}, nil)
+	srv.setServiceMode(serviceModeSystemd)
+	a.addServer(srv)
+
+	cmd, err := a.logFollowCommand("s", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := shellJoin("sudo", "-n", "/bin/sh", "-c", "exec journalctl -u "+massaServiceName+" -f -n 10 -o cat --no-pager"); cmd != want {
+		t.Errorf("follow command for a non-root user = %q, want %q", cmd, want)
+	}
+
+	root := newServerConn("s", "example.org", 22, "root", AuthOptions{}, nil)
+	if got := root.asRoot("systemctl is-active --quiet massa-node"); got != "systemctl is-active --quiet massa-node" {
+		t.Errorf("asRoot for root = %q, want the command unchanged", got)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logParseLines is how many lines of the node log are read for parsing and summaries.
+const logParseLines = 5000
+
+// Massa logs through tracing's default formatter:
+//
+//	2024-05-10T12:00:00.123456Z  WARN massa_protocol_worker::handlers::peer_handler: message
+//
+// The module may be followed by span fields in braces before the colon.
+var (
+	logLineRe    = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+)\s+(TRACE|DEBUG|INFO|WARN|ERROR)\s+([A-Za-z0-9_]+(?:::[A-Za-z0-9_]+)*)(?:\{[^}]*\})*:\s?(.*)$`)
+	ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// Key fields pulled out of messages. Massa IDs are base58 with a type prefix.
+	logSlotRe      = regexp.MustCompile(`\(period:\s*(\d+),\s*thread:\s*(\d+)\)`)
+	logBlockIDRe   = regexp.MustCompile(`\bB1[1-9A-HJ-NP-Za-km-z]{20,}\b`)
+	logOpIDRe      = regexp.MustCompile(`\bO1[1-9A-HJ-NP-Za-km-z]{20,}\b`)
+	logAddressRe   = regexp.MustCompile(`\bA[US]1[1-9A-HJ-NP-Za-km-z]{20,}\b`)
+	logNodeIDRe    = regexp.MustCompile(`\b[NP]1[1-9A-HJ-NP-Za-km-z]{20,}\b`)
+	logLevelByRank = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR"}
+)
+
+// LogRecord is one parsed node log entry. Lines that do not start a new record
+// (panics, multi-line dumps) are appended to the previous record's message.
+type LogRecord struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Level     string            `json:"level"`
+	Module    string            `json:"module"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields"` // slot, period, thread, blockId, operationId, address, nodeId
+	Raw       string            `json:"raw"`
+}
+
+// LogFilter selects records in GetParsedNodeLogs. Empty fields match everything.
+type LogFilter struct {
+	Levels   []string `json:"levels"`   // Exact levels, e.g. ["WARN", "ERROR"]
+	MinLevel string   `json:"minLevel"` // Lowest level to include, e.g. "WARN"
+	Modules  []string `json:"modules"`  // Module prefixes, e.g. "massa_protocol"
+	Since    string   `json:"since"`    // RFC 3339 timestamp
+	Until    string   `json:"until"`    // RFC 3339 timestamp
+	Pattern  string   `json:"pattern"`  // Regular expression matched against the raw line
+	Limit    int      `json:"limit"`    // Keep only the newest Limit records (0 = no limit)
+}
+
+// ModuleCount is the number of WARN/ERROR records logged by one module.
+type ModuleCount struct {
+	Module   string `json:"module"`
+	Warnings int    `json:"warnings"`
+	Errors   int    `json:"errors"`
+}
+
+// LogSummary gives the UI an overview of recent problems in the node log.
+type LogSummary struct {
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Total       int            `json:"total"`
+	LevelCounts map[string]int `json:"levelCounts"`
+	Warnings    int            `json:"warnings"`
+	Errors      int            `json:"errors"`
+	Modules     []ModuleCount  `json:"modules"` // Sorted by errors, then warnings
+	LastWarning *LogRecord     `json:"lastWarning,omitempty"`
+	LastError   *LogRecord     `json:"lastError,omitempty"`
+	Unparseable int            `json:"unparseable"` // Lines before the first recognised record
+}
+
+// parseLogLines turns raw node log lines into records.
+func parseLogLines(lines []string) ([]LogRecord, int) {
+	var records []LogRecord
+	unparseable := 0
+	for _, line := range lines {
+		line = strings.TrimRight(ansiEscapeRe.ReplaceAllString(line, ""), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := logLineRe.FindStringSubmatch(line)
+		if m == nil {
+			if len(records) == 0 {
+				unparseable++
+				continue
+			}
+			last := &records[len(records)-1]
+			last.Message += "\n" + line
+			last.Raw += "\n" + line
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, m[1])
+		if err != nil {
+			unparseable++
+			continue
+		}
+		records = append(records, LogRecord{
+			Timestamp: ts,
+			Level:     m[2],
+			Module:    m[3],
+			Message:   m[4],
+			Raw:       line,
+		})
+	}
+	for i := range records {
+		records[i].Fields = extractLogFields(records[i].Message)
+	}
+	return records, unparseable
+}
+
+// extractLogFields pulls slot and ID values out of a log message.
+func extractLogFields(message string) map[string]string {
+	fields := make(map[string]string)
+	if m := logSlotRe.FindStringSubmatch(message); m != nil {
+		fields["period"] = m[1]
+		fields["thread"] = m[2]
+		fields["slot"] = m[1] + ":" + m[2]
+	}
+	for name, re := range map[string]*regexp.Regexp{
+		"blockId":     logBlockIDRe,
+		"operationId": logOpIDRe,
+		"address":     logAddressRe,
+		"nodeId":      logNodeIDRe,
+	} {
+		if id := re.FindString(message); id != "" {
+			fields[name] = id
+		}
+	}
+	return fields
+}
+
+// levelRank orders levels from TRACE (0) to ERROR (4); unknown levels rank -1.
+func levelRank(level string) int {
+	for i, l := range logLevelByRank {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+	return -1
+}
+
+// compiledLogFilter is a LogFilter with its values parsed once.
+type compiledLogFilter struct {
+	levels  map[string]bool
+	minRank int
+	modules []string
+	since   time.Time
+	until   time.Time
+	pattern *regexp.Regexp
+	limit   int
+}
+
+func compileLogFilter(f LogFilter) (*compiledLogFilter, error) {
+	c := &compiledLogFilter{minRank: -1, modules: f.Modules, limit: f.Limit}
+	if len(f.Levels) > 0 {
+		c.levels = make(map[string]bool)
+		for _, l := range f.Levels {
+			if levelRank(l) < 0 {
+				return nil, fmt.Errorf("unknown log level %q", l)
+			}
+			c.levels[strings.ToUpper(l)] = true
+		}
+	}
+	if f.MinLevel != "" {
+		if c.minRank = levelRank(f.MinLevel); c.minRank < 0 {
+			return nil, fmt.Errorf("unknown log level %q", f.MinLevel)
+		}
+	}
+	var err error
+	if f.Since != "" {
+		if c.since, err = time.Parse(time.RFC3339, f.Since); err != nil {
+			return nil, fmt.Errorf("invalid since time: %w", err)
+		}
+	}
+	if f.Until != "" {
+		if c.until, err = time.Parse(time.RFC3339, f.Until); err != nil {
+			return nil, fmt.Errorf("invalid until time: %w", err)
+		}
+	}
+	if f.Pattern != "" {
+		if c.pattern, err = regexp.Compile(f.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (c *compiledLogFilter) match(r LogRecord) bool {
+	if c.levels != nil && !c.levels[r.Level] {
+		return false
+	}
+	if c.minRank >= 0 && levelRank(r.Level) < c.minRank {
+		return false
+	}
+	if len(c.modules) > 0 {
+		found := false
+		for _, m := range c.modules {
+			if strings.HasPrefix(r.Module, m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !c.since.IsZero() && r.Timestamp.Before(c.since) {
+		return false
+	}
+	if !c.until.IsZero() && r.Timestamp.After(c.until) {
+		return false
+	}
+	if c.pattern != nil && !c.pattern.MatchString(r.Raw) {
+		return false
+	}
+	return true
+}
+
+// filterLogRecords applies f to records, keeping the newest f.Limit matches.
+func filterLogRecords(records []LogRecord, f *compiledLogFilter) []LogRecord {
+	matched := make([]LogRecord, 0, len(records))
+	for _, r := range records {
+		if f.match(r) {
+			matched = append(matched, r)
+		}
+	}
+	if f.limit > 0 && len(matched) > f.limit {
+		matched = matched[len(matched)-f.limit:]
+	}
+	return matched
+}
+
+// summarizeLogRecords counts levels and per-module warnings/errors.
+func summarizeLogRecords(records []LogRecord) LogSummary {
+	summary := LogSummary{LevelCounts: make(map[string]int), Modules: []ModuleCount{}}
+	byModule := make(map[string]*ModuleCount)
+	for i := range records {
+		r := &records[i]
+		if summary.Total == 0 || r.Timestamp.Before(summary.From) {
+			summary.From = r.Timestamp
+		}
+		if r.Timestamp.After(summary.To) {
+			summary.To = r.Timestamp
+		}
+		summary.Total++
+		summary.LevelCounts[r.Level]++
+		if r.Level != "WARN" && r.Level != "ERROR" {
+			continue
+		}
+		mc, ok := byModule[r.Module]
+		if !ok {
+			mc = &ModuleCount{Module: r.Module}
+			byModule[r.Module] = mc
+		}
+		if r.Level == "ERROR" {
+			summary.Errors++
+			mc.Errors++
+			summary.LastError = r
+		} else {
+			summary.Warnings++
+			mc.Warnings++
+			summary.LastWarning = r
+		}
+	}
+	for _, mc := range byModule {
+		summary.Modules = append(summary.Modules, *mc)
+	}
+	sort.Slice(summary.Modules, func(i, j int) bool {
+		a, b := summary.Modules[i], summary.Modules[j]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.Warnings != b.Warnings {
+			return a.Warnings > b.Warnings
+		}
+		return a.Module < b.Module
+	})
+	return summary
+}
+
+// readNodeLogLines returns the last n lines of the node log, from logs.txt in
+// screen mode or from the journal in systemd mode. Unlike GetMassaNodeLogs it
+// does not go through a screen hardcopy, so long lines are not wrapped.
+func (a *App) readNodeLogLines(serverID string, n int) ([]string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	command := fmt.Sprintf("tail -n %d %s", n, shellQuote(srv.getLayout().nodeLogPath()))
+	if srv.getServiceMode() == serviceModeSystemd {
+		command = srv.asRoot(fmt.Sprintf("journalctl -u %s -n %d -o cat --no-pager", massaServiceName, n))
+	}
+	output, err := a.runReadOnlyCommand(serverID, command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node log: %w", err)
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// GetParsedNodeLogs returns the recent node log as structured records matching filter.
+func (a *App) GetParsedNodeLogs(serverID string, filter LogFilter) ([]LogRecord, error) {
+	compiled, err := compileLogFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := a.readNodeLogLines(serverID, logParseLines)
+	if err != nil {
+		return nil, err
+	}
+	records, _ := parseLogLines(lines)
+	return filterLogRecords(records, compiled), nil
+}
+
+// GetNodeLogSummary counts WARN/ERROR records from the last windowMinutes of the
+// node log (measured back from the newest record). windowMinutes <= 0 covers
+// everything that was read.
+func (a *App) GetNodeLogSummary(serverID string, windowMinutes int) (LogSummary, error) {
+	lines, err := a.readNodeLogLines(serverID, logParseLines)
+	if err != nil {
+		return LogSummary{}, err
+	}
+	records, unparseable := parseLogLines(lines)
+	if windowMinutes > 0 && len(records) > 0 {
+		cutoff := records[len(records)-1].Timestamp.Add(-time.Duration(windowMinutes) * time.Minute)
+		start := sort.Search(len(records), func(i int) bool { return !records[i].Timestamp.Before(cutoff) })
+		records = records[start:]
+	}
+	summary := summarizeLogRecords(records)
+	summary.Unparseable = unparseable
+	return summary, nil
+}
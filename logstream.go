@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// logStreamBuffer is how many lines may queue between the SSH reader and
+	// the event emitter. When the frontend cannot keep up, newer lines are
+	// dropped and counted rather than blocking the remote tail.
+	logStreamBuffer = 2000
+	// Lines are sent to the frontend in batches, at most every
+	// logStreamFlushInterval or once logStreamMaxBatch lines are queued.
+	logStreamFlushInterval = 200 * time.Millisecond
+	logStreamMaxBatch      = 500
+	// Delay between resume attempts after the stream breaks, doubling up to the max.
+	logStreamRetryMin = 2 * time.Second
+	logStreamRetryMax = 30 * time.Second
+	// logStreamMaxLine bounds a single log line; the node occasionally dumps large structs.
+	logStreamMaxLine = 1024 * 1024
+)
+
+// LogStreamBatch is emitted as a "log:lines" event with the next lines of a stream.
+type LogStreamBatch struct {
+	SubscriptionID string   `json:"subscriptionId"`
+	ServerID       string   `json:"serverId"`
+	Lines          []string `json:"lines"`
+	Dropped        int64    `json:"dropped"` // Lines discarded since the previous batch because the buffer was full
+}
+
+// LogStreamStatus is emitted as a "log:status" event when a stream changes state:
+// "streaming", "reconnecting" or "stopped".
+type LogStreamStatus struct {
+	SubscriptionID string `json:"subscriptionId"`
+	ServerID       string `json:"serverId"`
+	State          string `json:"state"`
+	Message        string `json:"message,omitempty"`
+}
+
+// logStream is one follow-mode subscription to a server's node log.
+type logStream struct {
+	id       string
+	serverID string
+	backlog  int
+
+	lines    chan string
+	dropped  int64 // Accessed atomically
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *logStream) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *logStream) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// logStreamRegistry tracks active log subscriptions by ID.
+type logStreamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*logStream
+}
+
+func newLogStreamRegistry() *logStreamRegistry {
+	return &logStreamRegistry{streams: make(map[string]*logStream)}
+}
+
+func (r *logStreamRegistry) add(s *logStream) {
+	r.mu.Lock()
+	r.streams[s.id] = s
+	r.mu.Unlock()
+}
+
+func (r *logStreamRegistry) remove(id string) *logStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.streams[id]
+	delete(r.streams, id)
+	return s
+}
+
+// stopServer stops every stream following serverID, or every stream when serverID is empty.
+func (r *logStreamRegistry) stopServer(serverID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, s := range r.streams {
+		if serverID == "" || s.serverID == serverID {
+			s.close()
+			delete(r.streams, id)
+		}
+	}
+}
+
+// StartLogStream starts following the node log on serverID and returns a
+// subscription ID. The last backlog lines are sent first, then new lines as
+// they are written, as "log:lines" events. If the SSH session breaks (for
+// example after a reconnect) the stream resumes on its own until StopLogStream.
+func (a *App) StartLogStream(serverID string, backlog int) (string, error) {
+	if a.ctx == nil {
+		return "", fmt.Errorf("frontend is not ready to receive log events")
+	}
+	if _, err := a.server(serverID); err != nil {
+		return "", err
+	}
+	if backlog < 0 {
+		backlog = 0
+	}
+
+	s := &logStream{
+		id:       randomID(),
+		serverID: serverID,
+		backlog:  backlog,
+		lines:    make(chan string, logStreamBuffer),
+		stop:     make(chan struct{}),
+	}
+	a.logStreams.add(s)
+	go a.emitLogBatches(s)
+	go a.runLogStream(s)
+	fmt.Printf("Started log stream %s for server %s\n", s.id, serverID)
+	return s.id, nil
+}
+
+// StopLogStream ends a subscription started with StartLogStream.
+func (a *App) StopLogStream(subscriptionID string) error {
+	s := a.logStreams.remove(subscriptionID)
+	if s == nil {
+		return fmt.Errorf("no log stream with id %s", subscriptionID)
+	}
+	s.close()
+	return nil
+}
+
+// logFollowCommand returns the remote command that follows the node log.
+// Only the first attempt replays backlog lines; resumed streams start at the
+// end of the log so lines are not sent twice.
+func (a *App) logFollowCommand(serverID string, backlog int) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "", err
+	}
+	if srv.getServiceMode() == serviceModeSystemd {
+		// exec, so the SIGTERM sent when the stream stops reaches journalctl
+		// rather than the shell sudo runs it in.
+		return srv.asRoot(fmt.Sprintf("exec journalctl -u %s -f -n %d -o cat --no-pager", massaServiceName, backlog)), nil
+	}
+	return fmt.Sprintf("tail -n %d -F %s", backlog, shellQuote(srv.getLayout().nodeLogPath())), nil
+}
+
+func (a *App) runLogStream(s *logStream) {
+	backlog := s.backlog
+	delay := logStreamRetryMin
+	for {
+		streamed, err := a.followLog(s, backlog)
+		if s.stopped() {
+			a.emitLogStatus(s, "stopped", "")
+			return
+		}
+		if streamed {
+			backlog = 0
+			delay = logStreamRetryMin
+		}
+
+		message := "log stream ended"
+		if err != nil {
+			message = err.Error()
+		}
+		fmt.Printf("Log stream %s for %s interrupted: %s\n", s.id, s.serverID, message)
+		a.emitLogStatus(s, "reconnecting", message)
+
+		select {
+		case <-s.stop:
+			a.emitLogStatus(s, "stopped", "")
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > logStreamRetryMax {
+			delay = logStreamRetryMax
+		}
+	}
+}
+
+// followLog runs one remote follow session until it ends or the stream is
+// stopped. streamed reports whether the session got as far as running.
+func (a *App) followLog(s *logStream, backlog int) (streamed bool, err error) {
+	command, err := a.logFollowCommand(s.serverID, backlog)
+	if err != nil {
+		return false, err
+	}
+	// Looked up on every attempt so a reconnected client is picked up.
+	srv, err := a.server(s.serverID)
+	if err != nil {
+		return false, err
+	}
+	client, err := srv.sshClient()
+	if err != nil {
+		return false, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return false, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return false, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	if err := session.Start(command); err != nil {
+		return false, fmt.Errorf("failed to start %q: %w", command, err)
+	}
+	a.emitLogStatus(s, "streaming", "")
+
+	// Closing the session unblocks the scanner below when the stream is stopped.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.stop:
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), logStreamMaxLine)
+	for scanner.Scan() {
+		select {
+		case s.lines <- scanner.Text():
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, session.Wait()
+}
+
+// emitLogBatches forwards queued lines to the frontend in batches until the stream stops.
+func (a *App) emitLogBatches(s *logStream) {
+	ticker := time.NewTicker(logStreamFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, logStreamMaxBatch)
+	flush := func() {
+		dropped := atomic.SwapInt64(&s.dropped, 0)
+		if len(batch) == 0 && dropped == 0 {
+			return
+		}
+		runtime.EventsEmit(a.ctx, "log:lines", LogStreamBatch{
+			SubscriptionID: s.id,
+			ServerID:       s.serverID,
+			Lines:          batch,
+			Dropped:        dropped,
+		})
+		batch = make([]string, 0, logStreamMaxBatch)
+	}
+
+	for {
+		select {
+		case line := <-s.lines:
+			batch = append(batch, line)
+			if len(batch) >= logStreamMaxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			flush()
+			return
+		}
+	}
+}
+
+func (a *App) emitLogStatus(s *logStream, state string, message string) {
+	runtime.EventsEmit(a.ctx, "log:status", LogStreamStatus{
+		SubscriptionID: s.id,
+		ServerID:       s.serverID,
+		State:          state,
+		Message:        message,
+	})
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The node reads massa-node/config/config.toml on top of its base_config, so
+// the file only holds the values that differ from the defaults. NodeConfig
+// covers the settings operators usually change; a nil field means the key is
+// not in the file. Keys NodeConfig does not know are kept as they are.
+type NodeConfig struct {
+	Logging   *LoggingConfig   `toml:"logging,omitempty" json:"logging,omitempty"`
+	Protocol  *ProtocolConfig  `toml:"protocol,omitempty" json:"protocol,omitempty"`
+	Bootstrap *BootstrapConfig `toml:"bootstrap,omitempty" json:"bootstrap,omitempty"`
+	API       *APIConfig       `toml:"api,omitempty" json:"api,omitempty"`
+	Metrics   *MetricsConfig   `toml:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// LoggingConfig is the [logging] section.
+type LoggingConfig struct {
+	Level *int `toml:"level,omitempty" json:"level,omitempty"` // 0 (errors) to 4 (trace)
+}
+
+// ProtocolConfig is the [protocol] section: how peers reach the node.
+type ProtocolConfig struct {
+	RoutableIP        *string `toml:"routable_ip,omitempty" json:"routableIp,omitempty"`
+	Bind              *string `toml:"bind,omitempty" json:"bind,omitempty"` // Peer-to-peer listen address, e.g. "[::]:31244"
+	MaxInConnections  *int    `toml:"max_in_connections,omitempty" json:"maxInConnections,omitempty"`
+	MaxOutConnections *int    `toml:"max_out_connections,omitempty" json:"maxOutConnections,omitempty"`
+}
+
+// BootstrapConfig is the [bootstrap] section.
+type BootstrapConfig struct {
+	Bind                      *string `toml:"bind,omitempty" json:"bind,omitempty"` // Bootstrap server listen address, e.g. "[::]:31245"
+	BootstrapProtocol         *string `toml:"bootstrap_protocol,omitempty" json:"bootstrapProtocol,omitempty"`
+	MaxSimultaneousBootstraps *int    `toml:"max_simultaneous_bootstraps,omitempty" json:"maxSimultaneousBootstraps,omitempty"`
+	BootstrapWhitelistPath    *string `toml:"bootstrap_whitelist_path,omitempty" json:"bootstrapWhitelistPath,omitempty"`
+	BootstrapBlacklistPath    *string `toml:"bootstrap_blacklist_path,omitempty" json:"bootstrapBlacklistPath,omitempty"`
+}
+
+// APIConfig is the [api] section.
+type APIConfig struct {
+	BindPrivate *string `toml:"bind_private,omitempty" json:"bindPrivate,omitempty"` // Default 127.0.0.1:33034
+	BindPublic  *string `toml:"bind_public,omitempty" json:"bindPublic,omitempty"`   // Default [::]:33035
+	BindAPI     *string `toml:"bind_api,omitempty" json:"bindApi,omitempty"`         // Default [::]:33036
+	EnableHTTP  *bool   `toml:"enable_http,omitempty" json:"enableHttp,omitempty"`
+	EnableWS    *bool   `toml:"enable_ws,omitempty" json:"enableWs,omitempty"`
+}
+
+// MetricsConfig is the [metrics] section.
+type MetricsConfig struct {
+	Enabled *bool   `toml:"enabled,omitempty" json:"enabled,omitempty"`
+	Bind    *string `toml:"bind,omitempty" json:"bind,omitempty"`
+}
+
+// NodeConfigFile is the node's config.toml as read from the server.
+type NodeConfigFile struct {
+	Path        string     `json:"path"`
+	Exists      bool       `json:"exists"`
+	Config      NodeConfig `json:"config"`
+	UnknownKeys []string   `json:"unknownKeys"` // "section.key" entries kept untouched on apply
+	Raw         string     `json:"raw"`
+}
+
+// NodeConfigChange describes rewriting config.toml with a new NodeConfig.
+type NodeConfigChange struct {
+	Path       string   `json:"path"`
+	Changed    bool     `json:"changed"`
+	Old        string   `json:"old"`
+	New        string   `json:"new"`
+	Diff       []string `json:"diff"`                 // Line diff of the settings, each line prefixed with " ", "-" or "+"
+	BackupPath string   `json:"backupPath,omitempty"` // Set once applied
+	Restarted  bool     `json:"restarted"`
+}
+
+var bootstrapProtocols = []string{"Both", "IPv4", "IPv6"}
+
+// nodeConfigPath returns where config.toml lives on serverID.
+func (a *App) nodeConfigPath(serverID string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(srv.getLayout().nodeDir(), "config", "config.toml"), nil
+}
+
+// readNodeConfig fetches config.toml. A missing file is not an error.
+func (a *App) readNodeConfig(serverID string) (*NodeConfigFile, map[string]interface{}, error) {
+	configPath, err := a.nodeConfigPath(serverID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := fmt.Sprintf("if [ -f %s ]; then echo EXISTS; cat %s; else echo MISSING; fi", shellQuote(configPath), shellQuote(configPath))
+	output, err := a.runReadOnlyCommand(serverID, cmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	marker, raw, _ := strings.Cut(output, "\n")
+	file := &NodeConfigFile{Path: configPath, Exists: strings.TrimSpace(marker) == "EXISTS", UnknownKeys: []string{}, Raw: raw}
+	if !file.Exists {
+		file.Raw = ""
+	}
+
+	values := map[string]interface{}{}
+	if _, err := toml.Decode(file.Raw, &values); err != nil {
+		return nil, nil, fmt.Errorf("%s is not valid TOML: %w", configPath, err)
+	}
+	if _, err := toml.Decode(file.Raw, &file.Config); err != nil {
+		return nil, nil, fmt.Errorf("%s has a value of the wrong type: %w", configPath, err)
+	}
+	known := nodeConfigKeys()
+	for section, value := range values {
+		table, ok := value.(map[string]interface{})
+		if !ok || known[section] == nil {
+			file.UnknownKeys = append(file.UnknownKeys, section)
+			continue
+		}
+		for key := range table {
+			if !known[section][key] {
+				file.UnknownKeys = append(file.UnknownKeys, section+"."+key)
+			}
+		}
+	}
+	sort.Strings(file.UnknownKeys)
+	return file, values, nil
+}
+
+// nodeConfigKeys lists the section and key names NodeConfig manages, from its toml tags.
+func nodeConfigKeys() map[string]map[string]bool {
+	keys := map[string]map[string]bool{}
+	configType := reflect.TypeOf(NodeConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		section := configType.Field(i)
+		sectionName, _, _ := strings.Cut(section.Tag.Get("toml"), ",")
+		keys[sectionName] = map[string]bool{}
+		sectionType := section.Type.Elem()
+		for j := 0; j < sectionType.NumField(); j++ {
+			keyName, _, _ := strings.Cut(sectionType.Field(j).Tag.Get("toml"), ",")
+			keys[sectionName][keyName] = true
+		}
+	}
+	return keys
+}
+
+// validateNodeConfig checks values the node would otherwise reject at start,
+// or accept and then misbehave with.
+func validateNodeConfig(cfg NodeConfig) error {
+	var problems []string
+	ports := map[int]string{}
+	checkBind := func(name string, bind *string) {
+		if bind == nil {
+			return
+		}
+		host, portStr, err := net.SplitHostPort(*bind)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		if host != "" && net.ParseIP(host) == nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not an IP address", name, host))
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("%s: invalid port %q", name, portStr))
+			return
+		}
+		if other, ok := ports[port]; ok {
+			problems = append(problems, fmt.Sprintf("%s: port %d is already used by %s", name, port, other))
+		}
+		ports[port] = name
+	}
+	checkNonNegative := func(name string, value *int) {
+		if value != nil && *value < 0 {
+			problems = append(problems, fmt.Sprintf("%s must not be negative", name))
+		}
+	}
+
+	if l := cfg.Logging; l != nil && l.Level != nil && (*l.Level < 0 || *l.Level > 4) {
+		problems = append(problems, "logging.level must be between 0 and 4")
+	}
+	if p := cfg.Protocol; p != nil {
+		if p.RoutableIP != nil {
+			if _, _, err := parseRoutableIP(*p.RoutableIP); err != nil {
+				problems = append(problems, fmt.Sprintf("protocol.routable_ip: %v", err))
+			}
+		}
+		checkBind("protocol.bind", p.Bind)
+		checkNonNegative("protocol.max_in_connections", p.MaxInConnections)
+		checkNonNegative("protocol.max_out_connections", p.MaxOutConnections)
+	}
+	if b := cfg.Bootstrap; b != nil {
+		checkBind("bootstrap.bind", b.Bind)
+		if b.BootstrapProtocol != nil {
+			valid := false
+			for _, p := range bootstrapProtocols {
+				valid = valid || *b.BootstrapProtocol == p
+			}
+			if !valid {
+				problems = append(problems, fmt.Sprintf("bootstrap.bootstrap_protocol must be one of %s", strings.Join(bootstrapProtocols, ", ")))
+			}
+		}
+		checkNonNegative("bootstrap.max_simultaneous_bootstraps", b.MaxSimultaneousBootstraps)
+	}
+	if api := cfg.API; api != nil {
+		checkBind("api.bind_private", api.BindPrivate)
+		checkBind("api.bind_public", api.BindPublic)
+		checkBind("api.bind_api", api.BindAPI)
+	}
+	if m := cfg.Metrics; m != nil {
+		checkBind("metrics.bind", m.Bind)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid node config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// renderNodeConfig replaces the managed keys of values with cfg and encodes
+// the result. Managed keys that are nil in cfg are removed, so the node falls
+// back to its defaults for them; everything else in values is kept.
+func renderNodeConfig(values map[string]interface{}, cfg NodeConfig) (string, error) {
+	// The node parses routable_ip as a bare address, so IPv6 is written
+	// without brackets and in canonical form.
+	if p := cfg.Protocol; p != nil && p.RoutableIP != nil {
+		if addr, _, err := parseRoutableIP(*p.RoutableIP); err == nil {
+			protocol, ip := *p, addr.String()
+			protocol.RoutableIP = &ip
+			cfg.Protocol = &protocol
+		}
+	}
+	var typed bytes.Buffer
+	if err := toml.NewEncoder(&typed).Encode(cfg); err != nil {
+		return "", err
+	}
+	managed := map[string]interface{}{}
+	if _, err := toml.Decode(typed.String(), &managed); err != nil {
+		return "", err
+	}
+
+	for section, keys := range nodeConfigKeys() {
+		table, ok := values[section].(map[string]interface{})
+		if !ok {
+			table = map[string]interface{}{}
+		}
+		for key := range keys {
+			delete(table, key)
+		}
+		if newTable, ok := managed[section].(map[string]interface{}); ok {
+			for key, value := range newTable {
+				table[key] = value
+			}
+		}
+		if len(table) == 0 {
+			delete(values, section)
+		} else {
+			values[section] = table
+		}
+	}
+
+	var out bytes.Buffer
+	enc := toml.NewEncoder(&out)
+	enc.Indent = ""
+	if err := enc.Encode(values); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// diffLines returns a line diff of a and b from their longest common subsequence.
+func diffLines(a string, b string) []string {
+	x := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	y := strings.Split(strings.TrimRight(b, "\n"), "\n")
+	if a == "" {
+		x = nil
+	}
+	if b == "" {
+		y = nil
+	}
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			diff = append(diff, " "+x[i])
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] > lcs[i+1][j]):
+			diff = append(diff, "+"+y[j])
+			j++
+		default:
+			diff = append(diff, "-"+x[i])
+			i++
+		}
+	}
+	return diff
+}
+
+// GetNodeConfig reads the node's config.toml from serverID.
+func (a *App) GetNodeConfig(serverID string) (*NodeConfigFile, error) {
+	file, _, err := a.readNodeConfig(serverID)
+	return file, err
+}
+
+// PreviewNodeConfig validates cfg and shows how config.toml would change,
+// without writing anything.
+func (a *App) PreviewNodeConfig(serverID string, cfg NodeConfig) (*NodeConfigChange, error) {
+	if err := validateNodeConfig(cfg); err != nil {
+		return nil, err
+	}
+	file, values, err := a.readNodeConfig(serverID)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderNodeConfig(values, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render config: %w", err)
+	}
+	// Re-encoding alone reorders keys and drops comments; that is not a change.
+	current := map[string]interface{}{}
+	if _, err := toml.Decode(file.Raw, &current); err != nil {
+		return nil, err
+	}
+	baseline, err := renderNodeConfig(current, file.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render config: %w", err)
+	}
+	return &NodeConfigChange{
+		Path:    file.Path,
+		Changed: rendered != baseline,
+		Old:     file.Raw,
+		New:     rendered,
+		Diff:    diffLines(baseline, rendered),
+	}, nil
+}
+
+// ApplyNodeConfig writes cfg to config.toml, keeping the previous file as
+// config.toml.bak-<timestamp>. The node only reads its config at start, so
+// with restart set it is restarted to pick the change up. Comments in the old
+// file are not carried over.
+func (a *App) ApplyNodeConfig(serverID string, cfg NodeConfig, restart bool) (*NodeConfigChange, error) {
+	fmt.Println("ApplyNodeConfig called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	change, err := a.PreviewNodeConfig(serverID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !change.Changed {
+		return change, nil
+	}
+
+	if change.Old != "" {
+		change.BackupPath = fmt.Sprintf("%s.bak-%s", change.Path, time.Now().UTC().Format("20060102T150405Z"))
+		if _, err := a.RunCommand(serverID, srv.asNodeUser(shellJoin("cp", "-p", change.Path, change.BackupPath))); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", change.Path, err)
+		}
+	}
+	writeCmd := srv.asNodeUser(shellJoin("sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", change.Path))
+	if _, err := a.runCommand(serverID, writeCmd, strings.NewReader(change.New)); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", change.Path, err)
+	}
+
+	if restart {
+		result, err := a.RestartMassaNode(serverID, "")
+		if err != nil {
+			return change, fmt.Errorf("config written but restart failed: %w", err)
+		}
+		change.Restarted = result.Success
+	}
+	return change, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func intPtr(v int) *int          { return &v }
+func stringPtr(v string) *string { return &v }
+
+func TestValidateNodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NodeConfig
+		wantErr string // Substring of the error, "" for a valid config
+	}{
+		{"empty", NodeConfig{}, ""},
+		{"valid", NodeConfig{
+			Logging:   &LoggingConfig{Level: intPtr(2)},
+			Protocol:  &ProtocolConfig{RoutableIP: stringPtr("93.184.216.34"), Bind: stringPtr("[::]:31244"), MaxInConnections: intPtr(0)},
+			Bootstrap: &BootstrapConfig{Bind: stringPtr("[::]:31245"), BootstrapProtocol: stringPtr("IPv4"), MaxSimultaneousBootstraps: intPtr(0)},
+			API:       &APIConfig{BindPrivate: stringPtr("127.0.0.1:33034")},
+		}, ""},
+		{"log level", NodeConfig{Logging: &LoggingConfig{Level: intPtr(5)}}, "logging.level"},
+		{"negative connections", NodeConfig{Protocol: &ProtocolConfig{MaxOutConnections: intPtr(-1)}}, "protocol.max_out_connections must not be negative"},
+		{"negative bootstraps", NodeConfig{Bootstrap: &BootstrapConfig{MaxSimultaneousBootstraps: intPtr(-3)}}, "bootstrap.max_simultaneous_bootstraps"},
+		{"routable ip", NodeConfig{Protocol: &ProtocolConfig{RoutableIP: stringPtr("node.example.com")}}, "protocol.routable_ip"},
+		{"bind without port", NodeConfig{Protocol: &ProtocolConfig{Bind: stringPtr("0.0.0.0")}}, "protocol.bind"},
+		{"bind host", NodeConfig{Metrics: &MetricsConfig{Bind: stringPtr("localhost:9898")}}, "not an IP address"},
+		{"port range", NodeConfig{API: &APIConfig{BindPublic: stringPtr("[::]:70000")}}, "invalid port"},
+		{"port clash", NodeConfig{
+			Protocol:  &ProtocolConfig{Bind: stringPtr("[::]:31244")},
+			Bootstrap: &BootstrapConfig{Bind: stringPtr("0.0.0.0:31244")},
+		}, "already used by protocol.bind"},
+		{"bootstrap protocol", NodeConfig{Bootstrap: &BootstrapConfig{BootstrapProtocol: stringPtr("ipv4")}}, "bootstrap.bootstrap_protocol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNodeConfig(tt.cfg)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("no error, want one containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderNodeConfig(t *testing.T) {
+	raw := `
+[protocol]
+routable_ip = "1.2.3.4"
+max_in_connections = 10
+keep_alive = true
+
+[logging]
+level = 3
+
+[execution]
+max_gas = 1000
+`
+	values := map[string]interface{}{}
+	if _, err := toml.Decode(raw, &values); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NodeConfig{
+		Protocol: &ProtocolConfig{RoutableIP: stringPtr("[2001:db8::0001]")},
+		Metrics:  &MetricsConfig{Bind: stringPtr("[::]:9898")},
+	}
+	rendered, err := renderNodeConfig(values, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]interface{}{}
+	if _, err := toml.Decode(rendered, &got); err != nil {
+		t.Fatalf("rendered config is not valid TOML: %v\n%s", err, rendered)
+	}
+	want := map[string]interface{}{
+		"protocol": map[string]interface{}{
+			"routable_ip": "2001:db8::1", // Brackets dropped, canonical form
+			"keep_alive":  true,          // Unknown key kept
+		},
+		"execution": map[string]interface{}{"max_gas": int64(1000)}, // Unknown section kept
+		"metrics":   map[string]interface{}{"bind": "[::]:9898"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered config:\n%s\ngot  %#v\nwant %#v", rendered, got, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", []string{" a", " b"}},
+		{"both empty", "", "", []string{}},
+		{"new file", "", "a\n", []string{"+a"}},
+		{"removed file", "a\n", "", []string{"-a"}},
+		{"changed line", "a\nb\nc\n", "a\nx\nc\n", []string{" a", "-b", "+x", " c"}},
+		{"insert and delete", "a\nb\nc", "b\nc\nd", []string{"-a", " b", " c", "+d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
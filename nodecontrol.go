@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Timeouts for each stage of a node shutdown. The node flushes its ledger on a
+// graceful stop, which can take a while on slow disks, so the first stage is
+// the most generous.
+const (
+	nodeStopGracefulTimeout = 90 * time.Second
+	nodeStopTermTimeout     = 30 * time.Second
+	nodeStopKillTimeout     = 10 * time.Second
+	nodeStopPollInterval    = 2 * time.Second
+)
+
+// Step statuses reported in NodeControlStep.
+const (
+	stepRunning = "running"
+	stepDone    = "done"
+	stepSkipped = "skipped"
+	stepFailed  = "failed"
+)
+
+// NodeControlStep is one stage of a stop or restart. Each step is also emitted
+// to the frontend as a "node:progress" event while the operation runs.
+type NodeControlStep struct {
+	ServerID string    `json:"serverId"`
+	Action   string    `json:"action"` // "stop" or "restart"
+	Step     string    `json:"step"`
+	Status   string    `json:"status"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// NodeControlResult is the outcome of StopMassaNode or RestartMassaNode.
+type NodeControlResult struct {
+	ServerID     string            `json:"serverId"`
+	Action       string            `json:"action"`
+	Success      bool              `json:"success"`
+	Steps        []NodeControlStep `json:"steps"`
+	ProcessState string            `json:"processState"` // "running" or "stopped"
+	PIDs         []string          `json:"pids"`         // Node PIDs still alive at the end, if any
+	NodeStatus   string            `json:"nodeStatus"`   // Same codes as CheckMassaNodeStatus
+}
+
+// nodeControl accumulates the steps of one operation and forwards them to the frontend.
+type nodeControl struct {
+	app    *App
+	result *NodeControlResult
+}
+
+func (a *App) newNodeControl(serverID string, action string) *nodeControl {
+	return &nodeControl{app: a, result: &NodeControlResult{ServerID: serverID, Action: action, Steps: []NodeControlStep{}}}
+}
+
+func (c *nodeControl) report(step string, status string, format string, args ...interface{}) {
+	s := NodeControlStep{
+		ServerID: c.result.ServerID,
+		Action:   c.result.Action,
+		Step:     step,
+		Status:   status,
+		Message:  fmt.Sprintf(format, args...),
+		Time:     time.Now(),
+	}
+	fmt.Printf("[%s] %s/%s: %s %s\n", s.ServerID, s.Action, s.Step, s.Status, s.Message)
+	c.result.Steps = append(c.result.Steps, s)
+	if c.app.ctx != nil {
+		runtime.EventsEmit(c.app.ctx, "node:progress", s)
+	}
+}
+
+// finish records the final process and node state.
+func (c *nodeControl) finish(success bool) *NodeControlResult {
+	serverID := c.result.ServerID
+	pids, _ := c.app.massaNodePIDs(serverID)
+	c.result.PIDs = pids
+	if len(pids) > 0 {
+		c.result.ProcessState = "running"
+	} else {
+		c.result.ProcessState = "stopped"
+	}
+	status, err := c.app.CheckMassaNodeStatus(serverID)
+	if err != nil {
+		status = "UNKNOWN"
+	}
+	c.result.NodeStatus = status
+	c.result.Success = success
+	return c.result
+}
+
+// massaNodePIDs returns the PIDs of running massa-node processes.
+func (a *App) massaNodePIDs(serverID string) ([]string, error) {
+	// pgrep exits 1 when nothing matches, so that case is folded into an empty result.
+	output, err := a.runReadOnlyCommand(serverID, "pgrep -x massa-node || true")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// waitForNodeExit polls until no massa-node process is left or timeout elapses.
+func (a *App) waitForNodeExit(serverID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		pids, err := a.massaNodePIDs(serverID)
+		if err == nil && len(pids) == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(nodeStopPollInterval)
+	}
+}
+
+// StopMassaNode stops the node on serverID. It first asks the node to shut down
+// cleanly with "node_stop" on its private API and, if the process is
+// still alive after a timeout, escalates to SIGTERM and finally SIGKILL.
+func (a *App) StopMassaNode(serverID string) (*NodeControlResult, error) {
+	fmt.Println("StopMassaNode called")
+	if _, err := a.server(serverID); err != nil {
+		return nil, err
+	}
+	ctl := a.newNodeControl(serverID, "stop")
+	err := a.stopMassaNode(serverID, ctl)
+	return ctl.finish(err == nil), err
+}
+
+// RestartMassaNode stops the node as StopMassaNode does and starts it again.
+// An empty nodePassword reuses the password from the last setup or start.
+func (a *App) RestartMassaNode(serverID string, nodePassword string) (*NodeControlResult, error) {
+	fmt.Println("RestartMassaNode called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	if nodePassword == "" {
+		nodePassword = srv.getNodePassword()
+	}
+	if nodePassword == "" {
+		return nil, fmt.Errorf("node password is required to restart Massa node")
+	}
+	if err := validNodePassword(nodePassword); err != nil {
+		return nil, err
+	}
+
+	ctl := a.newNodeControl(serverID, "restart")
+	if err := a.stopMassaNode(serverID, ctl); err != nil {
+		return ctl.finish(false), err
+	}
+
+	ctl.report("start", stepRunning, "Starting Massa node")
+	output, err := a.StartMassaNode(serverID, nodePassword)
+	if err != nil {
+		ctl.report("start", stepFailed, "%v", err)
+		return ctl.finish(false), err
+	}
+	ctl.report("start", stepDone, "%s", strings.TrimSpace(output))
+	return ctl.finish(true), nil
+}
+
+func (a *App) stopMassaNode(serverID string, ctl *nodeControl) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	systemd := srv.getServiceMode() == serviceModeSystemd
+
+	pids, err := a.massaNodePIDs(serverID)
+	if err != nil {
+		ctl.report("check", stepFailed, "Failed to look up node process: %v", err)
+		return err
+	}
+	if len(pids) == 0 {
+		ctl.report("check", stepDone, "Massa node is not running")
+		a.cleanupNodeSession(serverID, systemd, ctl)
+		return nil
+	}
+	ctl.report("check", stepDone, "Massa node is running (pid %s)", strings.Join(pids, ", "))
+
+	// 1. Graceful shutdown through the node's private API, falling back to
+	// massa-client when the API is not reachable.
+	ctl.report("graceful", stepRunning, "Sending node_stop, waiting up to %s", nodeStopGracefulTimeout)
+	stopErr := a.StopNodeAPI(serverID)
+	if stopErr != nil && srv.getNodePassword() != "" {
+		fmt.Printf("node_stop over the API failed (%v), trying massa-client\n", stopErr)
+		_, stopErr = a.RunMassaClientCommand(serverID, "node_stop")
+	}
+	if stopErr != nil {
+		ctl.report("graceful", stepFailed, "node_stop failed: %v", stopErr)
+	} else if a.waitForNodeExit(serverID, nodeStopGracefulTimeout) {
+		ctl.report("graceful", stepDone, "Massa node stopped gracefully")
+		a.cleanupNodeSession(serverID, systemd, ctl)
+		return nil
+	} else {
+		ctl.report("graceful", stepFailed, "Massa node still running after %s", nodeStopGracefulTimeout)
+	}
+
+	// 2. SIGTERM, then 3. SIGKILL. In systemd mode the signals go through
+	// systemctl so the unit does not treat the exit as a crash and restart it.
+	escalations := []struct {
+		step    string
+		signal  string
+		timeout time.Duration
+	}{
+		{"sigterm", "TERM", nodeStopTermTimeout},
+		{"sigkill", "KILL", nodeStopKillTimeout},
+	}
+	for _, e := range escalations {
+		var cmd string
+		if systemd {
+			cmd = srv.asRoot(fmt.Sprintf("systemctl kill --signal=SIG%s %s", e.signal, massaServiceName))
+			if e.signal == "TERM" {
+				cmd = srv.asRoot("systemctl stop --no-block " + massaServiceName)
+			}
+		} else {
+			cmd = srv.asNodeUser(fmt.Sprintf("pkill -%s -x massa-node || true", e.signal))
+		}
+		ctl.report(e.step, stepRunning, "Sending SIG%s, waiting up to %s", e.signal, e.timeout)
+		if _, err := a.RunCommand(serverID, cmd); err != nil {
+			ctl.report(e.step, stepFailed, "Failed to send SIG%s: %v", e.signal, err)
+			continue
+		}
+		if a.waitForNodeExit(serverID, e.timeout) {
+			ctl.report(e.step, stepDone, "Massa node exited after SIG%s", e.signal)
+			a.cleanupNodeSession(serverID, systemd, ctl)
+			return nil
+		}
+		ctl.report(e.step, stepFailed, "Massa node still running after SIG%s", e.signal)
+	}
+
+	return fmt.Errorf("massa node did not stop")
+}
+
+// cleanupNodeSession removes whatever was supervising the node process: the
+// screen session in screen mode, or the unit's active state in systemd mode.
+func (a *App) cleanupNodeSession(serverID string, systemd bool, ctl *nodeControl) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		ctl.report("cleanup", stepFailed, "%v", err)
+		return
+	}
+	cmd := srv.asNodeUser("screen -S massa_node -X quit 2>/dev/null; true")
+	if systemd {
+		cmd = srv.asRoot("systemctl stop " + massaServiceName)
+	}
+	if _, err := a.RunCommand(serverID, cmd); err != nil {
+		ctl.report("cleanup", stepFailed, "%v", err)
+		return
+	}
+	ctl.report("cleanup", stepDone, "Node session cleaned up")
+}
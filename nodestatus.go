@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sync states reported in NodeStatus.
+const (
+	syncStopped       = "stopped"
+	syncStarting      = "starting"      // Process up, API not answering yet
+	syncBootstrapping = "bootstrapping" // Downloading the initial state from a bootstrap server
+	syncSyncing       = "syncing"       // API up, execution lagging behind the current slot
+	syncSynced        = "synced"
+)
+
+// nodeSyncLagPeriods is how many periods execution may trail the current slot
+// before the node counts as syncing. A period is 16 seconds.
+const nodeSyncLagPeriods = 2
+
+// NodeProcess is one massa-node process.
+type NodeProcess struct {
+	PID        int     `json:"pid"`
+	UptimeSecs int64   `json:"uptimeSecs"`
+	MemoryMB   float64 `json:"memoryMb"` // Resident set size
+	CPUPercent float64 `json:"cpuPercent"`
+}
+
+// NodeStatus is a snapshot of a node, gathered in one round trip.
+type NodeStatus struct {
+	ServerID         string        `json:"serverId"`
+	State            string        `json:"state"` // Same codes as CheckMassaNodeStatus
+	Installed        bool          `json:"installed"`
+	Running          bool          `json:"running"`
+	Processes        []NodeProcess `json:"processes"`
+	UptimeSecs       int64         `json:"uptimeSecs"` // Of the oldest process
+	ServiceMode      string        `json:"serviceMode"`
+	ScreenSession    bool          `json:"screenSession"`
+	ServiceState     string        `json:"serviceState,omitempty"` // systemctl is-active output, systemd mode only
+	InstalledVersion string        `json:"installedVersion"`       // From the install's VERSION file
+	Version          string        `json:"version"`                // Reported by the running node's API
+	LastLogTime      *time.Time    `json:"lastLogTime"`
+	LogSize          int64         `json:"logSize"`
+	APIReachable     bool          `json:"apiReachable"`
+	APIError         string        `json:"apiError,omitempty"`
+	SyncState        string        `json:"syncState"` // "stopped", "starting", "bootstrapping", "syncing" or "synced"
+	SyncLagPeriods   int64         `json:"syncLagPeriods"`
+	ConnectedPeers   int           `json:"connectedPeers"`
+	CurrentCycle     uint64        `json:"currentCycle"`
+	NodeID           string        `json:"nodeId"`
+	CheckedAt        time.Time     `json:"checkedAt"`
+}
+
+// nodeStatusScript prints "key=value" facts about the node. Arguments: node
+// directory, log file, VERSION file, service mode, public API port, the
+// command listing the node user's screen sessions and the command printing the
+// last journal entry of the service.
+const nodeStatusScript = `
+NODE_DIR="$1"; LOG="$2"; VERSION_FILE="$3"; MODE="$4"; PORT="$5"; SCREEN_LIST="$6"; LAST_JOURNAL="$7"
+[ -f "${NODE_DIR}/massa-node" ] && echo "installed=1"
+for pid in $(pgrep -x massa-node); do
+    echo "proc=${pid} $(ps -o etimes=,rss=,pcpu= -p "${pid}" | tr -s ' ' | sed 's/^ //')"
+done
+sh -c "${SCREEN_LIST}" 2>/dev/null | grep -q "massa_node" && echo "screen=1"
+if [ "${MODE}" = "systemd" ]; then
+    echo "service=$(systemctl is-active massa-node 2>/dev/null)"
+    echo "journal_time=$(sh -c "${LAST_JOURNAL}" 2>/dev/null | cut -d' ' -f1)"
+fi
+[ -f "${VERSION_FILE}" ] && echo "version_file=$(head -n 1 "${VERSION_FILE}")"
+if [ -f "${LOG}" ]; then
+    echo "log_mtime=$(stat -c %Y "${LOG}")"
+    echo "log_size=$(stat -c %s "${LOG}")"
+    tail -n 50 "${LOG}" | grep -qi "bootstrap" && echo "log_bootstrap=1"
+fi
+REQUEST='{"jsonrpc":"2.0","id":1,"method":"get_status","params":[]}'
+if command -v curl > /dev/null 2>&1; then
+    echo "api=$(curl -s --max-time 5 -H 'Content-Type: application/json' -d "${REQUEST}" "http://127.0.0.1:${PORT}/" | tr -d '\n')"
+elif command -v wget > /dev/null 2>&1; then
+    echo "api=$(wget -qO- --timeout=5 --header='Content-Type: application/json' --post-data="${REQUEST}" "http://127.0.0.1:${PORT}/" | tr -d '\n')"
+else
+    echo "api_tool=none"
+fi
+true
+`
+
+// GetNodeStatus reports the state of the node on serverID: its processes and
+// their resource use, the screen session or service, versions, log activity,
+// and what the node's API says about sync and peers.
+func (a *App) GetNodeStatus(serverID string) (*NodeStatus, error) {
+	fmt.Println("GetNodeStatus called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	layout := srv.getLayout()
+	mode := srv.getServiceMode()
+	argv := []string{"sh", "-c", nodeStatusScript, "sh", layout.nodeDir(), layout.nodeLogPath(), layout.versionFile(),
+		mode, strconv.Itoa(massaPublicAPIPort), srv.asNodeUser("screen -list"),
+		srv.asRoot("journalctl -u " + massaServiceName + " -n 1 --no-pager -o short-unix -q")}
+	output, err := a.runReadOnlyCommand(serverID, shellJoin(argv...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check node status: %w", err)
+	}
+
+	status := &NodeStatus{ServerID: serverID, ServiceMode: mode, Processes: []NodeProcess{}, CheckedAt: time.Now()}
+	var apiResponse string
+	apiChecked, logBootstrap := false, false
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimRight(line, "\r"), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "installed":
+			status.Installed = true
+		case "proc":
+			fields := strings.Fields(value)
+			if len(fields) != 4 {
+				continue
+			}
+			p := NodeProcess{}
+			p.PID, _ = strconv.Atoi(fields[0])
+			p.UptimeSecs, _ = strconv.ParseInt(fields[1], 10, 64)
+			rssKB, _ := strconv.ParseFloat(fields[2], 64)
+			p.MemoryMB = rssKB / 1024
+			p.CPUPercent, _ = strconv.ParseFloat(fields[3], 64)
+			status.Processes = append(status.Processes, p)
+			if p.UptimeSecs > status.UptimeSecs {
+				status.UptimeSecs = p.UptimeSecs
+			}
+		case "screen":
+			status.ScreenSession = true
+		case "service":
+			status.ServiceState = value
+		case "version_file":
+			status.InstalledVersion = strings.TrimSpace(value)
+		case "log_mtime", "journal_time":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				t := time.Unix(int64(secs), 0)
+				if status.LastLogTime == nil || t.After(*status.LastLogTime) {
+					status.LastLogTime = &t
+				}
+			}
+		case "log_size":
+			status.LogSize, _ = strconv.ParseInt(value, 10, 64)
+		case "log_bootstrap":
+			logBootstrap = true
+		case "api":
+			apiChecked, apiResponse = true, value
+		}
+	}
+
+	status.Running = len(status.Processes) > 0
+	var rpc *RPCNodeStatus
+	if apiChecked {
+		rpc, err = parseNodeStatusResponse(apiResponse)
+	} else if status.Running {
+		// Neither curl nor wget on the server: ask through the SSH tunnel instead.
+		rpc, err = a.GetNodeAPIStatus(serverID)
+	}
+	if err != nil {
+		status.APIError = err.Error()
+	} else if rpc != nil {
+		status.APIReachable = true
+		status.Version = rpc.Version
+		status.NodeID = rpc.NodeID
+		status.CurrentCycle = rpc.CurrentCycle
+		status.ConnectedPeers = len(rpc.ConnectedNodes)
+		if rpc.LastSlot != nil && rpc.ExecutionStats.ActiveCursor != nil {
+			status.SyncLagPeriods = int64(rpc.LastSlot.Period) - int64(rpc.ExecutionStats.ActiveCursor.Period)
+		}
+	}
+
+	switch {
+	case !status.Running:
+		status.SyncState = syncStopped
+	case status.APIReachable && status.SyncLagPeriods > nodeSyncLagPeriods:
+		status.SyncState = syncSyncing
+	case status.APIReachable:
+		status.SyncState = syncSynced
+	case logBootstrap:
+		status.SyncState = syncBootstrapping
+	default:
+		status.SyncState = syncStarting
+	}
+	status.State = status.legacyState()
+	return status, nil
+}
+
+// parseNodeStatusResponse decodes a get_status JSON-RPC response.
+func parseNodeStatusResponse(response string) (*RPCNodeStatus, error) {
+	if strings.TrimSpace(response) == "" {
+		return nil, fmt.Errorf("node API on port %d is not reachable", massaPublicAPIPort)
+	}
+	var envelope rpcResponse
+	if err := json.Unmarshal([]byte(response), &envelope); err != nil {
+		return nil, fmt.Errorf("invalid get_status response: %w", err)
+	}
+	if envelope.Error != nil {
+		return nil, envelope.Error
+	}
+	var rpc RPCNodeStatus
+	if err := json.Unmarshal(envelope.Result, &rpc); err != nil {
+		return nil, fmt.Errorf("unexpected get_status result: %w", err)
+	}
+	return &rpc, nil
+}
+
+// legacyState maps the status to the codes CheckMassaNodeStatus returns.
+func (s *NodeStatus) legacyState() string {
+	switch {
+	case !s.Installed:
+		return "NOT_INSTALLED"
+	case s.ServiceMode == serviceModeSystemd && s.ServiceState == "active":
+		return "RUNNING"
+	case s.ServiceMode != serviceModeSystemd && s.ScreenSession:
+		return "RUNNING"
+	case s.LastLogTime == nil:
+		return "STOPPED_NO_LOGS"
+	case s.ServiceMode != serviceModeSystemd && s.LogSize == 0:
+		return "STOPPED_EMPTY_LOG"
+	}
+	return "STOPPED_WITH_LOGS"
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// uploadProgressInterval limits how often "install:upload" events are emitted.
+const uploadProgressInterval = 500 * time.Millisecond
+
+// massaArchiveFilePattern matches release archive names as published on
+// GitHub, capturing the version.
+var massaArchiveFilePattern = regexp.MustCompile(`^massa_(.+)_release_linux(?:_arm64)?\.tar\.gz$`)
+
+// UploadProgress is emitted to the frontend as "install:upload" events while
+// a release is uploaded. For a directory, Sent and Total count the bytes of
+// the files being packed rather than of the compressed stream.
+type UploadProgress struct {
+	ServerID string `json:"serverId"`
+	Source   string `json:"source"`
+	Sent     int64  `json:"sent"`
+	Total    int64  `json:"total"`
+	Done     bool   `json:"done"`
+}
+
+// uploadedRelease is a release archive the manager put on the server for the
+// setup script to install instead of downloading one.
+type uploadedRelease struct {
+	Path       string
+	SHA256     string
+	Version    string
+	VerifiedBy string // Source of the checksum the archive matched, "" if unverified
+}
+
+// uploadProgress forwards byte counts to the frontend, throttled.
+type uploadProgress struct {
+	app      *App
+	mu       sync.Mutex
+	progress UploadProgress
+	lastEmit time.Time
+}
+
+func (p *uploadProgress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Sent += int64(len(b))
+	if time.Since(p.lastEmit) >= uploadProgressInterval {
+		p.emit()
+	}
+	return len(b), nil
+}
+
+func (p *uploadProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Done = true
+	p.emit()
+}
+
+func (p *uploadProgress) emit() {
+	p.lastEmit = time.Now()
+	if p.app.ctx != nil {
+		runtime.EventsEmit(p.app.ctx, "install:upload", p.progress)
+	}
+}
+
+// InstallMassaFromLocal installs Massa on a server without internet access.
+// localPath is either a release archive as published on GitHub or a directory
+// of extracted binaries (the "massa" directory holding massa-node and
+// massa-client, or its parent). It is uploaded over SFTP with progress
+// reported as "install:upload" events, then the normal setup runs on it.
+//
+// An archive whose name carries a version installs as that version. It must
+// match expectedSHA256 when given, or else the checksum known for its release
+// asset (see GetMassaReleaseChecksum). A directory is packed on the fly and
+// recorded under the server's configured version; it cannot be checked against
+// any checksum. Archives without a known checksum and directories are refused
+// unless allowUnverified is set, and are then recorded as unverified.
+func (a *App) InstallMassaFromLocal(serverID string, nodePassword string, publicIp string, localPath string, expectedSHA256 string, forceReinstall bool, allowUnverified bool) (string, error) {
+	fmt.Printf("InstallMassaFromLocal called. Source: %s, Force Reinstall: %t, Allow Unverified: %t\n", localPath, forceReinstall, allowUnverified)
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+	if err := validNodePassword(nodePassword); err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+
+	var log strings.Builder
+	release := &uploadedRelease{Version: srv.getMassaVersion()}
+	archive, err := a.serverMassaArchive(serverID, release.Version)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+	var source func(w io.Writer, progress io.Writer) error
+	var total int64
+
+	if info.IsDir() {
+		if expectedSHA256 != "" {
+			err := fmt.Errorf("a SHA-256 can only be checked for a release archive, not a directory")
+			return fmt.Sprintf("Error: %v", err), err
+		}
+		if !allowUnverified {
+			err := fmt.Errorf("%s is a directory and cannot be checked against a published checksum; allow unverified installs to use it", localPath)
+			return fmt.Sprintf("Error: %v", err), err
+		}
+		root, err := massaReleaseRoot(localPath)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), err
+		}
+		total, err = dirSize(root)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), err
+		}
+		log.WriteString(fmt.Sprintf("Packing %s for upload as Massa %s (unverified)\n", root, release.Version))
+		source = func(w io.Writer, progress io.Writer) error {
+			return writeMassaTarball(w, root, progress)
+		}
+	} else {
+		// The published name says which architecture the archive is for, and
+		// it has to be the server's.
+		knownName := false
+		if m := massaArchiveFilePattern.FindStringSubmatch(filepath.Base(localPath)); m != nil {
+			if version, err := validMassaVersion(m[1]); err == nil {
+				release.Version, knownName = version, true
+				if archive, err = a.serverMassaArchive(serverID, version); err != nil {
+					return fmt.Sprintf("Error: %v", err), err
+				}
+				if m[0] != archive {
+					err := fmt.Errorf("%s is built for another architecture; this server needs %s", m[0], archive)
+					return fmt.Sprintf("Error: %v", err), err
+				}
+			}
+		}
+		sum, err := fileSHA256(localPath)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), err
+		}
+		expected := &MassaReleaseChecksum{Version: release.Version, Archive: archive, Source: checksumSourceUserSupplied}
+		var lookupErr error
+		switch {
+		case expectedSHA256 != "":
+			if expected.SHA256, err = normalizeSHA256(expectedSHA256); err != nil {
+				return fmt.Sprintf("Error: %v", err), err
+			}
+		case knownName:
+			expected, lookupErr = a.resolveMassaArchiveChecksum(release.Version, archive)
+		default:
+			lookupErr = fmt.Errorf("%s is not named like a Massa release archive, so no published checksum applies", filepath.Base(localPath))
+		}
+		switch {
+		case lookupErr != nil && !allowUnverified:
+			err := fmt.Errorf("cannot verify %s: %v; supply its SHA-256 or allow unverified installs", localPath, lookupErr)
+			return fmt.Sprintf("Error: %v", err), err
+		case lookupErr != nil:
+			log.WriteString(fmt.Sprintf("Warning: installing %s unverified: %v\n", localPath, lookupErr))
+		case expected.SHA256 != sum:
+			err := fmt.Errorf("SHA-256 of %s is %s, expected %s for %s (%s)", localPath, sum, expected.SHA256, archive, expected.Source)
+			return fmt.Sprintf("Error: %v", err), err
+		default:
+			release.VerifiedBy = expected.Source
+			log.WriteString(fmt.Sprintf("Archive matches the %s checksum for %s\n", expected.Source, archive))
+		}
+		total = info.Size()
+		source = func(w io.Writer, progress io.Writer) error {
+			f, err := os.Open(localPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(io.MultiWriter(w, progress), f)
+			return err
+		}
+	}
+
+	output, err := a.RunCommand(serverID, "umask 077 && mktemp -d /tmp/massa-upload.XXXXXXXX")
+	if err != nil {
+		return log.String(), fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	uploadDir := strings.TrimSpace(output)
+	if !strings.HasPrefix(uploadDir, "/tmp/massa-upload.") {
+		return log.String(), fmt.Errorf("unexpected mktemp output %q", output)
+	}
+	defer a.runArgs(serverID, []string{"rm", "-rf", uploadDir}, nil)
+
+	release.Path = path.Join(uploadDir, archive)
+	progress := &uploadProgress{app: a, progress: UploadProgress{ServerID: serverID, Source: localPath, Total: total}}
+	started := time.Now()
+	release.SHA256, err = a.uploadFile(srv, release.Path, func(w io.Writer) error { return source(w, progress) })
+	if err != nil {
+		return log.String(), err
+	}
+	progress.finish()
+	log.WriteString(fmt.Sprintf("Uploaded %s to %s in %s, SHA-256 %s\n", localPath, release.Path, time.Since(started).Round(time.Second), release.SHA256))
+
+	setupLog, err := a.setupMassaComponents(serverID, nodePassword, publicIp, forceReinstall, release)
+	return log.String() + setupLog, err
+}
+
+// uploadFile writes what write produces to remotePath over SFTP and returns
+// its SHA-256.
+func (a *App) uploadFile(srv *serverConn, remotePath string, write func(w io.Writer) error) (string, error) {
+	client, err := srv.sshClient()
+	if err != nil {
+		return "", err
+	}
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return "", fmt.Errorf("failed to start SFTP (is the sftp subsystem enabled in sshd?): %w", err)
+	}
+	defer sftpClient.Close()
+
+	remote, err := sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", remotePath, err)
+	}
+	hash := sha256.New()
+	writeErr := write(io.MultiWriter(remote, hash))
+	closeErr := remote.Close()
+	if writeErr != nil {
+		return "", fmt.Errorf("upload to %s failed: %w", remotePath, writeErr)
+	}
+	if closeErr != nil {
+		return "", fmt.Errorf("upload to %s failed: %w", remotePath, closeErr)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// massaReleaseRoot returns the directory holding massa-node and massa-client
+// binaries: dir itself or its "massa" subdirectory.
+func massaReleaseRoot(dir string) (string, error) {
+	for _, root := range []string{dir, filepath.Join(dir, "massa")} {
+		node, nodeErr := os.Stat(filepath.Join(root, "massa-node", "massa-node"))
+		client, clientErr := os.Stat(filepath.Join(root, "massa-client", "massa-client"))
+		if nodeErr == nil && clientErr == nil && node.Mode().IsRegular() && client.Mode().IsRegular() {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("%s does not contain massa-node/massa-node and massa-client/massa-client", dir)
+}
+
+// writeMassaTarball writes root as a gzipped tar with everything under
+// "massa/", the layout of the published release archives. File contents are
+// also written to progress.
+func writeMassaTarball(w io.Writer, root string, progress io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join("massa", filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		// Binaries packed on Windows have no executable bit to carry over.
+		if rel == filepath.Join("massa-node", "massa-node") || rel == filepath.Join("massa-client", "massa-client") {
+			header.Mode |= 0o755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(io.MultiWriter(tw, progress), f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// fileSHA256 returns the hex SHA-256 of a local file.
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
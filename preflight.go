@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Preflight check statuses.
+const (
+	preflightPass = "pass"
+	preflightWarn = "warn"
+	preflightFail = "fail"
+)
+
+// Resource thresholds for a node. Below the minimum the node cannot keep up
+// with the network; below the recommendation it runs but may miss blocks
+// under load.
+const (
+	preflightMinMemoryMB         = 8 * 1024
+	preflightRecommendedMemoryMB = 16 * 1024
+	preflightMinDiskMB           = 50 * 1024
+	preflightRecommendedDiskMB   = 200 * 1024
+	preflightRecommendedCPUs     = 4
+)
+
+// massaNodePorts are the ports the node listens on with its default config:
+// peer-to-peer, bootstrap, private API, public API and the new API.
+var massaNodePorts = []int{31244, 31245, massaPrivateAPIPort, massaPublicAPIPort, 33036}
+
+// preflightTool is a program the manager runs on the server and the packages
+// that provide it.
+type preflightTool struct {
+	name    string
+	apt     string
+	rpm     string
+	purpose string
+}
+
+var preflightTools = []preflightTool{
+	{"wget", "wget", "wget", "download releases"},
+	{"tar", "tar", "tar", "unpack releases"},
+	{"sha256sum", "coreutils", "coreutils", "verify releases"},
+	{"screen", "screen", "screen", "run the node in screen mode"},
+	{"script", "bsdutils", "util-linux", "type the wallet password into the node"},
+	{"pgrep", "procps", "procps-ng", "find the node process"},
+	{"runuser", "util-linux", "util-linux", "run the node as another user"},
+}
+
+// PreflightItem is one check of PreflightCheck.
+type PreflightItem struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"` // "pass", "warn" or "fail"
+	Message string `json:"message"`
+}
+
+// PreflightReport describes whether a server can run a Massa node.
+type PreflightReport struct {
+	ServerID       string          `json:"serverId"`
+	OS             string          `json:"os"`
+	Distro         string          `json:"distro"`
+	Arch           string          `json:"arch"`
+	ReleaseAsset   string          `json:"releaseAsset"` // Archive matching Arch, empty if there is none
+	CPUs           int             `json:"cpus"`
+	MemoryMB       int             `json:"memoryMb"`
+	DiskFreeMB     int             `json:"diskFreeMb"`     // Free space where the node storage goes
+	DiskPath       string          `json:"diskPath"`       // Directory DiskFreeMB was measured for
+	PackageManager string          `json:"packageManager"` // "apt", "dnf", "yum" or ""
+	MissingTools   []string        `json:"missingTools"`
+	Installed      []string        `json:"installed"` // Packages installed by this check
+	Items          []PreflightItem `json:"items"`
+	Passed         bool            `json:"passed"` // No check failed
+}
+
+func (r *PreflightReport) add(check string, status string, format string, args ...interface{}) {
+	r.Items = append(r.Items, PreflightItem{Check: check, Status: status, Message: fmt.Sprintf(format, args...)})
+	if status == preflightFail {
+		r.Passed = false
+	}
+}
+
+// preflightScript prints "key=value" facts about the server in one round
+// trip. Argument: the directory the node storage goes to, whose nearest
+// existing parent is used for the free space check.
+const preflightScript = `
+echo "os=$(uname -s)"
+echo "arch=$(uname -m)"
+if [ -r /etc/os-release ]; then . /etc/os-release; echo "distro=${PRETTY_NAME:-$ID}"; fi
+echo "cpus=$(nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo)"
+echo "mem_kb=$(awk '/^MemTotal:/ {print $2}' /proc/meminfo)"
+DIR="$1"
+while [ ! -d "${DIR}" ]; do DIR=$(dirname "${DIR}"); done
+echo "disk_kb=$(df -Pk "${DIR}" | awk 'NR==2 {print $4}')"
+for tool in wget tar sha256sum screen script pgrep runuser systemctl; do
+    command -v "${tool}" > /dev/null 2>&1 || echo "missing=${tool}"
+done
+for pm in apt-get dnf yum; do
+    if command -v "${pm}" > /dev/null 2>&1; then echo "pm=${pm}"; break; fi
+done
+if command -v ss > /dev/null 2>&1; then
+    ss -ltnH 2>/dev/null | awk '{print $4}' | sed 's/.*:/listen=/'
+else
+    netstat -ltn 2>/dev/null | awk 'NR>2 {print $4}' | sed 's/.*:/listen=/'
+fi
+pgrep -x massa-node > /dev/null && echo "node_running=1"
+true
+`
+
+// massaArchiveNameFor returns the release archive built for a server
+// architecture as reported by uname -m, or "" if Massa has no Linux build for it.
+func massaArchiveNameFor(version string, arch string) string {
+	switch arch {
+	case "x86_64", "amd64":
+		return massaArchiveName(version)
+	case "aarch64", "arm64":
+		return fmt.Sprintf("massa_%s_release_linux_arm64.tar.gz", version)
+	}
+	return ""
+}
+
+// serverMassaArchive returns the release archive of version built for the
+// architecture of serverID, failing if Massa has no Linux build for it.
+func (a *App) serverMassaArchive(serverID string, version string) (string, error) {
+	output, err := a.runReadOnlyCommand(serverID, "uname -m")
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the server architecture: %w", err)
+	}
+	arch := strings.TrimSpace(output)
+	archive := massaArchiveNameFor(version, arch)
+	if archive == "" {
+		return "", fmt.Errorf("no Massa build for the server architecture %q", arch)
+	}
+	return archive, nil
+}
+
+// PreflightCheck reports whether serverID can run a Massa node: OS and
+// architecture, memory, free disk, the node's ports and the tools the
+// installer relies on. With installMissing set, missing tools are installed
+// with the server's package manager and the check is run again.
+func (a *App) PreflightCheck(serverID string, installMissing bool) (*PreflightReport, error) {
+	fmt.Println("PreflightCheck called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := a.runPreflight(srv)
+	if err != nil || !installMissing || len(report.MissingTools) == 0 {
+		return report, err
+	}
+
+	packages, err := a.installPreflightTools(srv, report)
+	if err != nil {
+		report.add("install", preflightFail, "%v", err)
+		return report, err
+	}
+	rerun, err := a.runPreflight(srv)
+	if err != nil {
+		return report, err
+	}
+	rerun.Installed = packages
+	return rerun, nil
+}
+
+func (a *App) runPreflight(srv *serverConn) (*PreflightReport, error) {
+	layout := srv.getLayout()
+	// The ledger and peers live in the data directory when one is set.
+	diskPath := layout.InstallDir
+	if layout.DataDir != "" {
+		diskPath = layout.DataDir
+	}
+	output, err := a.runReadOnlyCommand(srv.id, shellJoin("sh", "-c", preflightScript, "sh", diskPath))
+	if err != nil {
+		return nil, fmt.Errorf("preflight check failed: %w", err)
+	}
+
+	report := &PreflightReport{ServerID: srv.id, DiskPath: diskPath, MissingTools: []string{}, Installed: []string{}, Items: []PreflightItem{}, Passed: true}
+	missing := map[string]bool{}
+	listening := map[int]bool{}
+	nodeRunning := false
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "os":
+			report.OS = value
+		case "arch":
+			report.Arch = value
+		case "distro":
+			report.Distro = value
+		case "cpus":
+			report.CPUs, _ = strconv.Atoi(value)
+		case "mem_kb":
+			kb, _ := strconv.Atoi(value)
+			report.MemoryMB = kb / 1024
+		case "disk_kb":
+			kb, _ := strconv.Atoi(value)
+			report.DiskFreeMB = kb / 1024
+		case "missing":
+			missing[value] = true
+		case "pm":
+			report.PackageManager = strings.TrimSuffix(value, "-get")
+		case "listen":
+			if port, err := strconv.Atoi(value); err == nil {
+				listening[port] = true
+			}
+		case "node_running":
+			nodeRunning = true
+		}
+	}
+
+	// Platform
+	if report.OS != "Linux" {
+		report.add("os", preflightFail, "Massa node needs Linux, server runs %s", report.OS)
+	} else {
+		report.add("os", preflightPass, "%s", strings.TrimSpace("Linux "+report.Distro))
+	}
+	report.ReleaseAsset = massaArchiveNameFor(srv.getMassaVersion(), report.Arch)
+	switch {
+	case report.ReleaseAsset == "":
+		report.add("arch", preflightFail, "No Massa build for %s", report.Arch)
+	default:
+		report.add("arch", preflightPass, "%s (%s)", report.Arch, report.ReleaseAsset)
+	}
+
+	// Resources
+	switch {
+	case report.CPUs == 0:
+		report.add("cpu", preflightWarn, "Could not determine the number of CPUs")
+	case report.CPUs < preflightRecommendedCPUs:
+		report.add("cpu", preflightWarn, "%d CPUs, %d or more recommended", report.CPUs, preflightRecommendedCPUs)
+	default:
+		report.add("cpu", preflightPass, "%d CPUs", report.CPUs)
+	}
+	switch {
+	case report.MemoryMB < preflightMinMemoryMB:
+		report.add("memory", preflightFail, "%d MB of RAM, at least %d MB needed", report.MemoryMB, preflightMinMemoryMB)
+	case report.MemoryMB < preflightRecommendedMemoryMB:
+		report.add("memory", preflightWarn, "%d MB of RAM, %d MB recommended", report.MemoryMB, preflightRecommendedMemoryMB)
+	default:
+		report.add("memory", preflightPass, "%d MB of RAM", report.MemoryMB)
+	}
+	switch {
+	case report.DiskFreeMB < preflightMinDiskMB:
+		report.add("disk", preflightFail, "%d MB free for %s, at least %d MB needed", report.DiskFreeMB, diskPath, preflightMinDiskMB)
+	case report.DiskFreeMB < preflightRecommendedDiskMB:
+		report.add("disk", preflightWarn, "%d MB free for %s, %d MB recommended", report.DiskFreeMB, diskPath, preflightRecommendedDiskMB)
+	default:
+		report.add("disk", preflightPass, "%d MB free for %s", report.DiskFreeMB, diskPath)
+	}
+
+	// Ports
+	var busy []string
+	for _, port := range massaNodePorts {
+		if listening[port] {
+			busy = append(busy, strconv.Itoa(port))
+		}
+	}
+	switch {
+	case len(busy) == 0:
+		report.add("ports", preflightPass, "Node ports are free")
+	case nodeRunning:
+		report.add("ports", preflightPass, "Ports %s are in use by the running node", strings.Join(busy, ", "))
+	default:
+		report.add("ports", preflightFail, "Ports %s are already in use by another program", strings.Join(busy, ", "))
+	}
+
+	// Tools. screen is only needed in screen mode and systemctl in systemd mode;
+	// runuser only when the node runs as a different user.
+	systemd := srv.getServiceMode() == serviceModeSystemd
+	if systemd && missing["systemctl"] {
+		report.add("systemd", preflightFail, "Service mode is systemd but systemctl is not available")
+	}
+	for _, tool := range preflightTools {
+		switch {
+		case !missing[tool.name]:
+			continue
+		case tool.name == "screen" && systemd, tool.name == "runuser" && layout.RunAsUser == "":
+			report.add("tools", preflightWarn, "%s is missing (not needed with the current settings)", tool.name)
+		default:
+			report.MissingTools = append(report.MissingTools, tool.name)
+			report.add("tools", preflightFail, "%s is missing, needed to %s", tool.name, tool.purpose)
+		}
+	}
+	if len(report.MissingTools) == 0 {
+		report.add("tools", preflightPass, "Required tools are installed")
+	} else if report.PackageManager == "" {
+		report.add("tools", preflightWarn, "No supported package manager found, install %s manually", strings.Join(report.MissingTools, ", "))
+	}
+	return report, nil
+}
+
+// installPreflightTools installs the packages providing report.MissingTools
+// and returns their names.
+func (a *App) installPreflightTools(srv *serverConn, report *PreflightReport) ([]string, error) {
+	packageSet := map[string]bool{}
+	for _, name := range report.MissingTools {
+		for _, tool := range preflightTools {
+			if tool.name != name {
+				continue
+			}
+			if report.PackageManager == "apt" {
+				packageSet[tool.apt] = true
+			} else {
+				packageSet[tool.rpm] = true
+			}
+		}
+	}
+	packages := make([]string, 0, len(packageSet))
+	for p := range packageSet {
+		packages = append(packages, p)
+	}
+	sort.Strings(packages)
+
+	var cmd string
+	switch report.PackageManager {
+	case "apt":
+		cmd = "DEBIAN_FRONTEND=noninteractive apt-get update -q && DEBIAN_FRONTEND=noninteractive " +
+			shellJoin(append([]string{"apt-get", "install", "-y", "-q"}, packages...)...)
+	case "dnf", "yum":
+		cmd = shellJoin(append([]string{report.PackageManager, "install", "-y", "-q"}, packages...)...)
+	default:
+		return nil, fmt.Errorf("no supported package manager to install %s", strings.Join(packages, ", "))
+	}
+	if output, err := a.RunCommand(srv.id, srv.asRoot(cmd)); err != nil {
+		return nil, fmt.Errorf("failed to install %s: %w: %s", strings.Join(packages, ", "), err, lastLines(output, 5))
+	}
+	return packages, nil
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
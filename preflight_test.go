@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetupInstallsTheServerArchitectureArchive(t *testing.T) {
+	tests := []struct {
+		arch    string
+		want    string // Archive passed to the setup script
+		wantErr string
+	}{
+		{"x86_64", massaArchiveName(defaultMassaVersion), ""},
+		{"aarch64", "massa_" + defaultMassaVersion + "_release_linux_arm64.tar.gz", ""},
+		{"riscv64", "", "no Massa build"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			a, fake := newFakeServerApp(t, func(command string) (string, uint32, bool) {
+				if command == "uname -m" {
+					return tt.arch + "\n", 0, true
+				}
+				return "", 0, false
+			})
+			if err := a.checksums.set(defaultMassaVersion, strings.Repeat("ab", 32)); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			captureStdout(t, func() {
+				_, err = a.SetupAndRunMassaComponents(fakeServerID, testNodePassword, "93.184.216.34", false)
+			})
+
+			scriptPath := shellQuote(NodeLayout{InstallDir: defaultInstallDir}.setupScriptPath())
+			var scriptRun string
+			for _, c := range fake.commands() {
+				if strings.HasPrefix(c.command, scriptPath+" ") {
+					scriptRun = c.command
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("setup error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if scriptRun != "" {
+					t.Errorf("setup script ran on an unsupported architecture: %q", scriptRun)
+				}
+				return
+			}
+			if !strings.HasSuffix(scriptRun, " "+shellQuote(tt.want)) {
+				t.Errorf("setup script run as %q, want %s as the archive", scriptRun, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"github.com/zalando/go-keyring"
+)
+
+// How profile secrets are protected at rest.
+const (
+	profileEncryptionPassphrase = "passphrase" // Key derived from a master passphrase
+	profileEncryptionKeyring    = "keyring"    // Random key kept in the OS keyring
+)
+
+const (
+	keyringService     = "massa-node-manager"
+	keyringProfilesKey = "profiles-key"
+	profilesVerifier   = "massa-node-manager-profiles"
+	profileFileVersion = 1
+)
+
+// ServerProfile is a saved server. Secrets are kept separately in ProfileSecrets
+// and never leave the backend unencrypted except when connecting.
+type ServerProfile struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Host         string   `json:"host"`
+	Port         int      `json:"port"`
+	User         string   `json:"user"`
+	AuthMethod   string   `json:"authMethod"` // One of the auth method names, or "" for the default chain
+	KeyPath      string   `json:"keyPath"`
+	InstallDir   string   `json:"installDir"`   // Install base directory, defaultInstallDir if empty
+	RunAsUser    string   `json:"runAsUser"`    // Account running the node, "" for the SSH user
+	DataDir      string   `json:"dataDir"`      // Node storage directory, "" for the default inside InstallDir
+	ServiceMode  string   `json:"serviceMode"`  // "screen" (default) or "systemd"
+	MassaVersion string   `json:"massaVersion"` // Massa release to install, defaultMassaVersion if empty
+	Tags         []string `json:"tags"`
+}
+
+// ProfileSecrets holds the sensitive part of a profile.
+type ProfileSecrets struct {
+	Password      string `json:"password"`
+	KeyPassphrase string `json:"keyPassphrase"`
+	NodePassword  string `json:"nodePassword"`
+}
+
+// ProfileStoreStatus tells the frontend whether it needs to set up or unlock the store.
+type ProfileStoreStatus struct {
+	Initialized bool   `json:"initialized"`
+	Encryption  string `json:"encryption"`
+	Unlocked    bool   `json:"unlocked"`
+}
+
+// storedProfile is a profile as written to disk, with its secrets encrypted.
+type storedProfile struct {
+	ServerProfile
+	Secrets string `json:"secrets,omitempty"`
+}
+
+// profileFile is the on-disk format of profiles.json (and of exports).
+type profileFile struct {
+	Version    int             `json:"version"`
+	Encryption string          `json:"encryption"`
+	Salt       string          `json:"salt,omitempty"`
+	Verifier   string          `json:"verifier,omitempty"`
+	Profiles   []storedProfile `json:"profiles"`
+}
+
+// profileStore loads and saves server profiles. key is nil while the store is locked.
+type profileStore struct {
+	mu   sync.Mutex
+	path string
+	data *profileFile
+	key  []byte
+}
+
+func newProfileStore(path string) *profileStore {
+	return &profileStore{path: path}
+}
+
+// load reads the profile file from disk, if it exists. Keyring-protected stores
+// are unlocked right away.
+func (s *profileStore) load() error {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.data = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profiles: %w", err)
+	}
+	var data profileFile
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("failed to parse profiles: %w", err)
+	}
+	s.data = &data
+
+	if data.Encryption == profileEncryptionKeyring && s.key == nil {
+		encoded, err := keyring.Get(keyringService, keyringProfilesKey)
+		if err != nil {
+			return fmt.Errorf("failed to read profile key from OS keyring: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid profile key in OS keyring: %w", err)
+		}
+		if err := s.checkKey(key); err != nil {
+			return err
+		}
+		s.key = key
+	}
+	return nil
+}
+
+// save writes the profile file atomically with owner-only permissions.
+func (s *profileStore) save() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode profiles: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write profiles: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace profiles file: %w", err)
+	}
+	return nil
+}
+
+// checkKey verifies key against the stored verifier.
+func (s *profileStore) checkKey(key []byte) error {
+	plain, err := openString(key, s.data.Verifier)
+	if err != nil || string(plain) != profilesVerifier {
+		return fmt.Errorf("wrong master passphrase")
+	}
+	return nil
+}
+
+// unlocked returns an error unless the store exists and its key is available.
+func (s *profileStore) unlocked() error {
+	if s.data == nil {
+		if err := s.load(); err != nil {
+			return err
+		}
+	}
+	if s.data == nil {
+		return fmt.Errorf("profile store is not set up yet")
+	}
+	if s.key == nil {
+		return fmt.Errorf("profile store is locked, unlock it with the master passphrase first")
+	}
+	return nil
+}
+
+func (s *profileStore) find(id string) (int, error) {
+	for i, p := range s.data.Profiles {
+		if p.ID == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("profile %q not found", id)
+}
+
+func (s *profileStore) encryptSecrets(secrets ProfileSecrets) (string, error) {
+	if secrets == (ProfileSecrets{}) {
+		return "", nil
+	}
+	raw, err := json.Marshal(secrets)
+	if err != nil {
+		return "", err
+	}
+	return sealString(s.key, raw)
+}
+
+func (s *profileStore) decryptSecrets(encoded string) (ProfileSecrets, error) {
+	return decryptProfileSecrets(s.key, encoded)
+}
+
+func decryptProfileSecrets(key []byte, encoded string) (ProfileSecrets, error) {
+	var secrets ProfileSecrets
+	if encoded == "" {
+		return secrets, nil
+	}
+	raw, err := openString(key, encoded)
+	if err != nil {
+		return secrets, err
+	}
+	err = json.Unmarshal(raw, &secrets)
+	return secrets, err
+}
+
+// profileAndSecrets returns a profile with its decrypted secrets.
+func (s *profileStore) profileAndSecrets(id string) (ServerProfile, ProfileSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.unlocked(); err != nil {
+		return ServerProfile{}, ProfileSecrets{}, err
+	}
+	i, err := s.find(id)
+	if err != nil {
+		return ServerProfile{}, ProfileSecrets{}, err
+	}
+	secrets, err := s.decryptSecrets(s.data.Profiles[i].Secrets)
+	return s.data.Profiles[i].ServerProfile, secrets, err
+}
+
+// validateProfile checks the fields every profile needs and fills in defaults.
+func validateProfile(p *ServerProfile) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Host = strings.TrimSpace(p.Host)
+	p.User = strings.TrimSpace(p.User)
+	if p.Host == "" || p.User == "" {
+		return fmt.Errorf("host and user are required")
+	}
+	if p.Port == 0 {
+		p.Port = 22
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		return fmt.Errorf("invalid port %d", p.Port)
+	}
+	if p.Name == "" {
+		p.Name = fmt.Sprintf("%s@%s", p.User, p.Host)
+	}
+	switch p.AuthMethod {
+	case "", authMethodAgent, authMethodPublicKey, authMethodPassword, authMethodKeyboardInteractive:
+	default:
+		return fmt.Errorf("unknown authentication method %q", p.AuthMethod)
+	}
+	mode, err := validServiceMode(p.ServiceMode)
+	if err != nil {
+		return err
+	}
+	p.ServiceMode = mode
+	version, err := validMassaVersion(p.MassaVersion)
+	if err != nil {
+		return err
+	}
+	p.MassaVersion = version
+	layout, err := validNodeLayout(NodeLayout{InstallDir: p.InstallDir, RunAsUser: p.RunAsUser, DataDir: p.DataDir})
+	if err != nil {
+		return err
+	}
+	p.InstallDir, p.RunAsUser, p.DataDir = layout.InstallDir, layout.RunAsUser, layout.DataDir
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	return nil
+}
+
+// validateProfileSecrets checks the secrets given with a profile. Empty values
+// are allowed: they are simply not saved.
+func validateProfileSecrets(secrets ProfileSecrets) error {
+	if secrets.NodePassword != "" {
+		return validNodePassword(secrets.NodePassword)
+	}
+	return nil
+}
+
+// GetProfileStoreStatus reports whether saved profiles exist and are unlocked.
+func (a *App) GetProfileStoreStatus() (ProfileStoreStatus, error) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.load(); err != nil {
+		return ProfileStoreStatus{}, err
+	}
+	if a.profiles.data == nil {
+		return ProfileStoreStatus{}, nil
+	}
+	return ProfileStoreStatus{
+		Initialized: true,
+		Encryption:  a.profiles.data.Encryption,
+		Unlocked:    a.profiles.key != nil,
+	}, nil
+}
+
+// InitProfileStore creates an empty profile store. encryption is "passphrase"
+// (secrets encrypted with a key derived from passphrase) or "keyring" (a random
+// key stored in the OS keyring, passphrase is ignored).
+func (a *App) InitProfileStore(encryption string, passphrase string) error {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.load(); err != nil {
+		return err
+	}
+	if a.profiles.data != nil {
+		return fmt.Errorf("profile store already exists")
+	}
+
+	data := &profileFile{Version: profileFileVersion, Encryption: encryption, Profiles: []storedProfile{}}
+	var key []byte
+	switch encryption {
+	case profileEncryptionPassphrase:
+		if len(passphrase) < 8 {
+			return fmt.Errorf("master passphrase must be at least 8 characters")
+		}
+		salt, err := newSalt()
+		if err != nil {
+			return err
+		}
+		key, err = deriveKey(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		data.Salt = base64.StdEncoding.EncodeToString(salt)
+	case profileEncryptionKeyring:
+		var err error
+		key, err = newDataKey()
+		if err != nil {
+			return err
+		}
+		if err := keyring.Set(keyringService, keyringProfilesKey, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return fmt.Errorf("failed to store profile key in OS keyring: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown encryption mode %q", encryption)
+	}
+
+	verifier, err := sealString(key, []byte(profilesVerifier))
+	if err != nil {
+		return err
+	}
+	data.Verifier = verifier
+	a.profiles.data = data
+	a.profiles.key = key
+	fmt.Printf("Profile store created (%s encryption).\n", encryption)
+	return a.profiles.save()
+}
+
+// UnlockProfiles unlocks a passphrase-protected profile store.
+func (a *App) UnlockProfiles(passphrase string) error {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.load(); err != nil {
+		return err
+	}
+	if a.profiles.data == nil {
+		return fmt.Errorf("profile store is not set up yet")
+	}
+	if a.profiles.data.Encryption != profileEncryptionPassphrase {
+		return nil // Keyring stores are unlocked by load
+	}
+	salt, err := base64.StdEncoding.DecodeString(a.profiles.data.Salt)
+	if err != nil {
+		return fmt.Errorf("invalid salt in profile store: %w", err)
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	if err := a.profiles.checkKey(key); err != nil {
+		return err
+	}
+	a.profiles.key = key
+	return nil
+}
+
+// LockProfiles forgets the decryption key until the next UnlockProfiles.
+func (a *App) LockProfiles() {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if a.profiles.data != nil && a.profiles.data.Encryption == profileEncryptionPassphrase {
+		a.profiles.key = nil
+	}
+}
+
+// ListProfiles returns all saved profiles, without secrets, sorted by name.
+func (a *App) ListProfiles() ([]ServerProfile, error) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.load(); err != nil {
+		return nil, err
+	}
+	profiles := []ServerProfile{}
+	if a.profiles.data == nil {
+		return profiles, nil
+	}
+	for _, p := range a.profiles.data.Profiles {
+		profiles = append(profiles, p.ServerProfile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// CreateProfile saves a new profile and returns it with its generated ID.
+func (a *App) CreateProfile(profile ServerProfile, secrets ProfileSecrets) (ServerProfile, error) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.unlocked(); err != nil {
+		return ServerProfile{}, err
+	}
+	if err := validateProfile(&profile); err != nil {
+		return ServerProfile{}, err
+	}
+	if err := validateProfileSecrets(secrets); err != nil {
+		return ServerProfile{}, err
+	}
+	profile.ID = randomID()
+	encrypted, err := a.profiles.encryptSecrets(secrets)
+	if err != nil {
+		return ServerProfile{}, err
+	}
+	a.profiles.data.Profiles = append(a.profiles.data.Profiles, storedProfile{ServerProfile: profile, Secrets: encrypted})
+	if err := a.profiles.save(); err != nil {
+		return ServerProfile{}, err
+	}
+	fmt.Printf("Profile %s (%s) created.\n", profile.ID, profile.Name)
+	return profile, nil
+}
+
+// UpdateProfile replaces the profile with the same ID. Empty secret fields keep
+// their stored value, so the frontend never needs to read secrets back.
+func (a *App) UpdateProfile(profile ServerProfile, secrets ProfileSecrets) (ServerProfile, error) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.unlocked(); err != nil {
+		return ServerProfile{}, err
+	}
+	i, err := a.profiles.find(profile.ID)
+	if err != nil {
+		return ServerProfile{}, err
+	}
+	if err := validateProfile(&profile); err != nil {
+		return ServerProfile{}, err
+	}
+	if err := validateProfileSecrets(secrets); err != nil {
+		return ServerProfile{}, err
+	}
+
+	current, err := a.profiles.decryptSecrets(a.profiles.data.Profiles[i].Secrets)
+	if err != nil {
+		return ServerProfile{}, err
+	}
+	if secrets.Password != "" {
+		current.Password = secrets.Password
+	}
+	if secrets.KeyPassphrase != "" {
+		current.KeyPassphrase = secrets.KeyPassphrase
+	}
+	if secrets.NodePassword != "" {
+		current.NodePassword = secrets.NodePassword
+	}
+	encrypted, err := a.profiles.encryptSecrets(current)
+	if err != nil {
+		return ServerProfile{}, err
+	}
+
+	a.profiles.data.Profiles[i] = storedProfile{ServerProfile: profile, Secrets: encrypted}
+	if err := a.profiles.save(); err != nil {
+		return ServerProfile{}, err
+	}
+	return profile, nil
+}
+
+// DeleteProfile removes a saved profile.
+func (a *App) DeleteProfile(profileID string) error {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.load(); err != nil {
+		return err
+	}
+	if a.profiles.data == nil {
+		return fmt.Errorf("profile store is not set up yet")
+	}
+	i, err := a.profiles.find(profileID)
+	if err != nil {
+		return err
+	}
+	a.profiles.data.Profiles = append(a.profiles.data.Profiles[:i], a.profiles.data.Profiles[i+1:]...)
+	return a.profiles.save()
+}
+
+// ConnectProfile connects to a saved server. The profile ID is used as the server ID.
+func (a *App) ConnectProfile(profileID string) (string, error) {
+	profile, secrets, err := a.profiles.profileAndSecrets(profileID)
+	if err != nil {
+		return fmt.Sprintf("Error loading profile: %v", err), err
+	}
+
+	auth := AuthOptions{
+		Password:      secrets.Password,
+		KeyPath:       profile.KeyPath,
+		KeyPassphrase: secrets.KeyPassphrase,
+	}
+	if profile.AuthMethod != "" {
+		auth.Methods = []string{profile.AuthMethod}
+		if profile.AuthMethod != authMethodKeyboardInteractive {
+			auth.Methods = append(auth.Methods, authMethodKeyboardInteractive)
+		}
+	}
+
+	result, err := a.ConnectToServerWithAuth(profile.ID, profile.Host, profile.Port, profile.User, auth)
+	if err != nil {
+		return result, err
+	}
+	if srv, err := a.server(profile.ID); err == nil {
+		if secrets.NodePassword != "" {
+			if err := validNodePassword(secrets.NodePassword); err != nil {
+				fmt.Printf("Warning: not using the node password saved in profile %s: %v\n", profile.ID, err)
+			} else {
+				srv.setNodePassword(secrets.NodePassword)
+			}
+		}
+		if mode, err := validServiceMode(profile.ServiceMode); err == nil {
+			srv.setServiceMode(mode)
+		}
+		if version, err := validMassaVersion(profile.MassaVersion); err == nil {
+			srv.setMassaVersion(version)
+		}
+		if layout, err := validNodeLayout(NodeLayout{InstallDir: profile.InstallDir, RunAsUser: profile.RunAsUser, DataDir: profile.DataDir}); err == nil {
+			srv.setLayout(layout)
+		}
+	}
+	return result, nil
+}
+
+// ExportProfiles writes the saved profiles to a file chosen by the user. When
+// exportPassphrase is empty only the non-secret fields are exported; otherwise
+// secrets are re-encrypted with a key derived from exportPassphrase.
+func (a *App) ExportProfiles(exportPassphrase string) (string, error) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.unlocked(); err != nil {
+		return "", err
+	}
+
+	export := profileFile{Version: profileFileVersion, Profiles: []storedProfile{}}
+	var exportKey []byte
+	if exportPassphrase != "" {
+		salt, err := newSalt()
+		if err != nil {
+			return "", err
+		}
+		exportKey, err = deriveKey(exportPassphrase, salt)
+		if err != nil {
+			return "", err
+		}
+		verifier, err := sealString(exportKey, []byte(profilesVerifier))
+		if err != nil {
+			return "", err
+		}
+		export.Encryption = profileEncryptionPassphrase
+		export.Salt = base64.StdEncoding.EncodeToString(salt)
+		export.Verifier = verifier
+	}
+
+	for _, p := range a.profiles.data.Profiles {
+		entry := storedProfile{ServerProfile: p.ServerProfile}
+		if exportKey != nil && p.Secrets != "" {
+			raw, err := openString(a.profiles.key, p.Secrets)
+			if err != nil {
+				return "", err
+			}
+			if entry.Secrets, err = sealString(exportKey, raw); err != nil {
+				return "", err
+			}
+		}
+		export.Profiles = append(export.Profiles, entry)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export server profiles",
+		DefaultFilename: "massa-server-profiles.json",
+		Filters:         []runtime.FileFilter{{DisplayName: "JSON files", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return "", err // Empty path means the user cancelled
+	}
+	raw, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	return fmt.Sprintf("Exported %d profile(s) to %s.", len(export.Profiles), path), nil
+}
+
+// ImportProfiles adds the profiles from a file chosen by the user. Imported
+// profiles get new IDs. exportPassphrase decrypts secrets if the file has any.
+func (a *App) ImportProfiles(exportPassphrase string) (string, error) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import server profiles",
+		Filters: []runtime.FileFilter{{DisplayName: "JSON files", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read import file: %w", err)
+	}
+	var imported profileFile
+	if err := json.Unmarshal(raw, &imported); err != nil {
+		return "", fmt.Errorf("invalid profile file: %w", err)
+	}
+
+	var importKey []byte
+	if imported.Encryption == profileEncryptionPassphrase {
+		salt, err := base64.StdEncoding.DecodeString(imported.Salt)
+		if err != nil {
+			return "", fmt.Errorf("invalid salt in import file: %w", err)
+		}
+		if importKey, err = deriveKey(exportPassphrase, salt); err != nil {
+			return "", err
+		}
+		if plain, err := openString(importKey, imported.Verifier); err != nil || string(plain) != profilesVerifier {
+			return "", fmt.Errorf("wrong passphrase for import file")
+		}
+	}
+
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	if err := a.profiles.unlocked(); err != nil {
+		return "", err
+	}
+	count := 0
+	for _, p := range imported.Profiles {
+		profile := p.ServerProfile
+		if err := validateProfile(&profile); err != nil {
+			fmt.Printf("Skipping imported profile %q: %v\n", profile.Name, err)
+			continue
+		}
+		profile.ID = randomID()
+		var secrets ProfileSecrets
+		if importKey != nil {
+			if secrets, err = decryptProfileSecrets(importKey, p.Secrets); err != nil {
+				return "", err
+			}
+		}
+		encrypted, err := a.profiles.encryptSecrets(secrets)
+		if err != nil {
+			return "", err
+		}
+		a.profiles.data.Profiles = append(a.profiles.data.Profiles, storedProfile{ServerProfile: profile, Secrets: encrypted})
+		count++
+	}
+	if err := a.profiles.save(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Imported %d profile(s) from %s.", count, path), nil
+}
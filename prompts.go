@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// promptTimeout is how long the backend waits for the user to answer a prompt
+// that was sent to the frontend before giving up.
+const promptTimeout = 2 * time.Minute
+
+// promptReply is the answer the frontend sends back for a pending prompt.
+type promptReply struct {
+	Accept  bool
+	Answers []string // Keyboard-interactive answers, in question order
+}
+
+// promptRegistry keeps track of prompts that were emitted to the frontend and
+// are waiting for the user to answer them.
+type promptRegistry struct {
+	mu      sync.Mutex
+	pending map[string]chan promptReply
+}
+
+func newPromptRegistry() *promptRegistry {
+	return &promptRegistry{pending: make(map[string]chan promptReply)}
+}
+
+// open registers a new prompt and returns its ID and the channel the reply will arrive on.
+func (r *promptRegistry) open() (string, chan promptReply) {
+	id := randomID()
+	ch := make(chan promptReply, 1)
+	r.mu.Lock()
+	r.pending[id] = ch
+	r.mu.Unlock()
+	return id, ch
+}
+
+// close forgets a prompt, whether or not it was answered.
+func (r *promptRegistry) close(id string) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+}
+
+// resolve delivers the user's answer to the goroutine waiting on the prompt.
+func (r *promptRegistry) resolve(id string, reply promptReply) error {
+	r.mu.Lock()
+	ch, ok := r.pending[id]
+	delete(r.pending, id)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no pending prompt with id %s (it may have timed out)", id)
+	}
+	ch <- reply
+	return nil
+}
+
+// askFrontend emits eventName to the frontend and blocks until the user answers
+// the prompt or promptTimeout elapses. The payload is built by the caller from
+// the generated prompt ID so the frontend can echo it back.
+func (a *App) askFrontend(eventName string, buildPayload func(id string) interface{}) (promptReply, error) {
+	if a.ctx == nil {
+		return promptReply{}, fmt.Errorf("frontend is not ready to answer prompts")
+	}
+
+	id, ch := a.prompts.open()
+	defer a.prompts.close(id)
+
+	runtime.EventsEmit(a.ctx, eventName, buildPayload(id))
+
+	select {
+	case reply := <-ch:
+		return reply, nil
+	case <-time.After(promptTimeout):
+		return promptReply{}, fmt.Errorf("timed out waiting for user confirmation")
+	}
+}
+
+// randomID returns a random hex identifier suitable for prompts and other
+// short-lived handles shared with the frontend.
+func randomID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to the clock just in case.
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Address scopes reported for IP candidates. Only "public" addresses are
+// reachable by other nodes without port forwarding.
+const (
+	ipScopePublic    = "public"
+	ipScopePrivate   = "private"    // RFC 1918 and IPv6 unique local
+	ipScopeShared    = "shared"     // Carrier-grade NAT, 100.64.0.0/10
+	ipScopeLoopback  = "loopback"   // 127.0.0.0/8 and ::1
+	ipScopeLinkLocal = "link-local" // 169.254.0.0/16 and fe80::/10
+	ipScopeReserved  = "reserved"   // Documentation, benchmarking and other non-routable ranges
+)
+
+// nonRoutablePrefixes are ranges that netip has no predicate for.
+var (
+	sharedAddressPrefix = netip.MustParsePrefix("100.64.0.0/10")
+	nonRoutablePrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("100::/64"),
+	}
+)
+
+// publicIPServices return the caller's address as seen from the internet, one
+// per address family.
+var publicIPServices = map[int]string{
+	4: "https://api.ipify.org",
+	6: "https://api6.ipify.org",
+}
+
+// IPCandidate is an address the node could announce as its routable_ip.
+type IPCandidate struct {
+	Address   string `json:"address"`   // Canonical form, as written to config.toml
+	Version   int    `json:"version"`   // 4 or 6
+	Scope     string `json:"scope"`     // "public", "private", "shared", "loopback", "link-local" or "reserved"
+	Interface string `json:"interface"` // Interface holding the address, empty for the external lookup
+	Source    string `json:"source"`    // "interface" or "external"
+}
+
+// PublicIPReport lists the addresses found on a server.
+type PublicIPReport struct {
+	ServerID   string        `json:"serverId"`
+	Candidates []IPCandidate `json:"candidates"`
+	Suggested  string        `json:"suggested"` // Best candidate for routable_ip, empty if none is public
+	Warnings   []string      `json:"warnings"`
+}
+
+// classifyIP returns the scope of addr.
+func classifyIP(addr netip.Addr) string {
+	switch {
+	case addr.IsLoopback():
+		return ipScopeLoopback
+	case addr.IsLinkLocalUnicast():
+		return ipScopeLinkLocal
+	case addr.IsPrivate():
+		return ipScopePrivate
+	case sharedAddressPrefix.Contains(addr):
+		return ipScopeShared
+	case addr.IsUnspecified(), addr.IsMulticast(), !addr.IsGlobalUnicast():
+		return ipScopeReserved
+	}
+	for _, prefix := range nonRoutablePrefixes {
+		if prefix.Contains(addr) {
+			return ipScopeReserved
+		}
+	}
+	return ipScopePublic
+}
+
+// parseRoutableIP validates an address for protocol.routable_ip and returns
+// it in canonical form. IPv6 may be given in brackets; IPv4-mapped IPv6 is
+// reduced to IPv4. Zones, unspecified and multicast addresses are rejected.
+// The warnings explain why other nodes may be unable to reach the address.
+func parseRoutableIP(value string) (netip.Addr, []string, error) {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+		trimmed = trimmed[1 : len(trimmed)-1]
+	}
+	addr, err := netip.ParseAddr(trimmed)
+	if err != nil {
+		return netip.Addr{}, nil, fmt.Errorf("%q is not an IP address", value)
+	}
+	if addr.Zone() != "" {
+		return netip.Addr{}, nil, fmt.Errorf("%q has an interface zone; routable_ip must be reachable from other hosts", value)
+	}
+	addr = addr.Unmap()
+	if addr.IsUnspecified() || addr.IsMulticast() {
+		return netip.Addr{}, nil, fmt.Errorf("%s cannot be used as a routable address", addr)
+	}
+
+	var warnings []string
+	switch scope := classifyIP(addr); scope {
+	case ipScopePublic:
+	case ipScopePrivate, ipScopeShared:
+		warnings = append(warnings, fmt.Sprintf("%s is a %s address: the node is behind NAT and needs ports 31244 and 31245 forwarded to be reachable", addr, scope))
+	default:
+		warnings = append(warnings, fmt.Sprintf("%s is a %s address: other nodes cannot reach it", addr, scope))
+	}
+	return addr, warnings, nil
+}
+
+// DetectPublicIPs lists the addresses on the server's interfaces and the
+// addresses the server reaches the internet from, and suggests one for
+// routable_ip. A public interface address is preferred, IPv4 before IPv6.
+func (a *App) DetectPublicIPs(serverID string) (*PublicIPReport, error) {
+	fmt.Println("DetectPublicIPs called")
+	if _, err := a.server(serverID); err != nil {
+		return nil, err
+	}
+	report := &PublicIPReport{ServerID: serverID, Candidates: []IPCandidate{}, Warnings: []string{}}
+	seen := map[netip.Addr]bool{}
+	add := func(value string, iface string, source string) {
+		addr, err := netip.ParseAddr(strings.TrimSpace(value))
+		if err != nil || addr.Zone() != "" {
+			return
+		}
+		addr = addr.Unmap()
+		if seen[addr] || addr.IsLoopback() || addr.IsUnspecified() {
+			return
+		}
+		seen[addr] = true
+		version := 4
+		if addr.Is6() {
+			version = 6
+		}
+		report.Candidates = append(report.Candidates, IPCandidate{
+			Address: addr.String(), Version: version, Scope: classifyIP(addr), Interface: iface, Source: source,
+		})
+	}
+
+	// "ip -o addr" prints one address per line: index, interface, family, address/prefix.
+	output, err := a.runReadOnlyCommand(serverID, "ip -o addr show scope global 2>/dev/null || true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list server addresses: %w", err)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		address, _, _ := strings.Cut(fields[3], "/")
+		add(address, fields[1], "interface")
+	}
+	if len(report.Candidates) == 0 {
+		output, _ = a.runReadOnlyCommand(serverID, "hostname -I 2>/dev/null || true")
+		for _, address := range strings.Fields(output) {
+			add(address, "", "interface")
+		}
+	}
+
+	external := map[int]string{}
+	for _, version := range []int{4, 6} {
+		cmd := fmt.Sprintf("curl -%d -fsS --max-time 5 %s 2>/dev/null || wget -%d -qO- --timeout=5 %s 2>/dev/null || true",
+			version, publicIPServices[version], version, publicIPServices[version])
+		output, err := a.runReadOnlyCommand(serverID, cmd)
+		if err != nil {
+			continue
+		}
+		if addr, err := netip.ParseAddr(strings.TrimSpace(output)); err == nil {
+			external[version] = addr.Unmap().String()
+			add(external[version], "", "external")
+		}
+	}
+
+	for _, version := range []int{4, 6} {
+		for _, c := range report.Candidates {
+			if report.Suggested == "" && c.Version == version && c.Scope == ipScopePublic {
+				report.Suggested = c.Address
+			}
+		}
+	}
+	if report.Suggested == "" {
+		report.Warnings = append(report.Warnings, "No public address found; the node cannot be reached by other nodes without port forwarding")
+	}
+	if ext := external[4]; ext != "" {
+		local := false
+		for _, c := range report.Candidates {
+			if c.Address == ext && c.Source == "interface" {
+				local = true
+			}
+		}
+		if !local {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("The server reaches the internet as %s, which is not on any of its interfaces: it is behind NAT, so ports 31244 and 31245 must be forwarded to it", ext))
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Default ports of the node's JSON-RPC APIs. Both only listen on the server
+// itself by default, so requests are tunneled through the SSH connection.
+const (
+	massaPrivateAPIPort = 33034
+	massaPublicAPIPort  = 33035
+	rpcTimeout          = 20 * time.Second
+)
+
+var rpcRequestID int64
+
+// RPCError is an error returned by the node's JSON-RPC API.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("node API error %d: %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// callNodeRPC calls method on the node API listening on port on the server's
+// loopback interface and decodes the result into result (which may be nil).
+func (a *App) callNodeRPC(serverID string, port int, method string, params interface{}, result interface{}) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: atomic.AddInt64(&rpcRequestID, 1), Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	client := &http.Client{
+		Timeout: rpcTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				client, err := srv.sshClient()
+				if err != nil {
+					return nil, err
+				}
+				conn, err := client.Dial(network, addr)
+				var refused *ssh.OpenChannelError
+				if err != nil && !errors.As(err, &refused) {
+					// Anything other than the server refusing the forward means the link is gone.
+					err = fmt.Errorf("%w: %v", errConnectionLost, err)
+				}
+				return conn, err
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("node API on port %d is not reachable: %w", port, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("node API returned HTTP %d for %s: %s", resp.StatusCode, method, bytes.TrimSpace(data))
+	}
+
+	var envelope rpcResponse
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("invalid %s response: %w", method, err)
+	}
+	if envelope.Error != nil {
+		return envelope.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("unexpected %s result: %w", method, err)
+	}
+	return nil
+}
+
+// callNodeRPCReadOnly is callNodeRPC for methods without side effects: if the
+// SSH link drops, it waits for the supervisor to reconnect and retries once.
+func (a *App) callNodeRPCReadOnly(serverID string, port int, method string, params interface{}, result interface{}) error {
+	err := a.callNodeRPC(serverID, port, method, params, result)
+	if errors.Is(err, errConnectionLost) && a.waitForReconnect(serverID, readOnlyRetryWait) {
+		fmt.Printf("Retrying %s on %s after reconnect\n", method, serverID)
+		return a.callNodeRPC(serverID, port, method, params, result)
+	}
+	return err
+}
+
+// RPCSlot identifies a slot in the block graph.
+type RPCSlot struct {
+	Period uint64 `json:"period"`
+	Thread uint8  `json:"thread"`
+}
+
+// RPCConnectedNode is one peer from get_status. The API encodes it as an
+// [ip, is_outgoing] pair keyed by node ID.
+type RPCConnectedNode struct {
+	IP       string `json:"ip"`
+	Outgoing bool   `json:"outgoing"`
+}
+
+func (n *RPCConnectedNode) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("expected [ip, outgoing], got %d elements", len(pair))
+	}
+	if err := json.Unmarshal(pair[0], &n.IP); err != nil {
+		return err
+	}
+	return json.Unmarshal(pair[1], &n.Outgoing)
+}
+
+// RPCConsensusStats is the consensus_stats part of get_status.
+type RPCConsensusStats struct {
+	StartTimespan   uint64 `json:"start_timespan"`
+	EndTimespan     uint64 `json:"end_timespan"`
+	FinalBlockCount uint64 `json:"final_block_count"`
+	StaleBlockCount uint64 `json:"stale_block_count"`
+	CliqueCount     uint64 `json:"clique_count"`
+}
+
+// RPCNetworkStats is the network_stats part of get_status.
+type RPCNetworkStats struct {
+	InConnectionCount  uint64 `json:"in_connection_count"`
+	OutConnectionCount uint64 `json:"out_connection_count"`
+	KnownPeerCount     uint64 `json:"known_peer_count"`
+	BannedPeerCount    uint64 `json:"banned_peer_count"`
+	ActiveNodeCount    uint64 `json:"active_node_count"`
+}
+
+// RPCExecutionStats is the execution_stats part of get_status.
+type RPCExecutionStats struct {
+	TimeWindowStart              uint64   `json:"time_window_start"`
+	TimeWindowEnd                uint64   `json:"time_window_end"`
+	FinalBlockCount              uint64   `json:"final_block_count"`
+	FinalExecutedOperationsCount uint64   `json:"final_executed_operations_count"`
+	ActiveCursor                 *RPCSlot `json:"active_cursor"`
+	FinalCursor                  *RPCSlot `json:"final_cursor"`
+}
+
+// RPCNodeStatus is the result of the public get_status method.
+type RPCNodeStatus struct {
+	NodeID           string                      `json:"node_id"`
+	NodeIP           *string                     `json:"node_ip"`
+	Version          string                      `json:"version"`
+	CurrentTime      uint64                      `json:"current_time"` // Milliseconds since the epoch
+	CurrentCycle     uint64                      `json:"current_cycle"`
+	CurrentCycleTime uint64                      `json:"current_cycle_time"`
+	NextCycleTime    uint64                      `json:"next_cycle_time"`
+	ConnectedNodes   map[string]RPCConnectedNode `json:"connected_nodes"`
+	LastSlot         *RPCSlot                    `json:"last_slot"`
+	NextSlot         RPCSlot                     `json:"next_slot"`
+	ConsensusStats   RPCConsensusStats           `json:"consensus_stats"`
+	PoolStats        []uint64                    `json:"pool_stats"` // [operations, endorsements]
+	NetworkStats     RPCNetworkStats             `json:"network_stats"`
+	ExecutionStats   RPCExecutionStats           `json:"execution_stats"`
+	ChainID          uint64                      `json:"chain_id"`
+	MinimalFees      string                      `json:"minimal_fees"`
+}
+
+// RPCDeferredCredit is an amount that will be credited to an address at a later slot.
+type RPCDeferredCredit struct {
+	Slot   RPCSlot `json:"slot"`
+	Amount string  `json:"amount"`
+}
+
+// RPCEndorsementDraw is a slot and endorsement index an address was selected for.
+type RPCEndorsementDraw struct {
+	Slot  RPCSlot `json:"slot"`
+	Index uint64  `json:"index"`
+}
+
+// RPCCycleInfo is an address's production record for one cycle.
+type RPCCycleInfo struct {
+	Cycle       uint64  `json:"cycle"`
+	IsFinal     bool    `json:"is_final"`
+	OkCount     uint64  `json:"ok_count"`
+	NokCount    uint64  `json:"nok_count"`
+	ActiveRolls *uint64 `json:"active_rolls"`
+}
+
+// RPCAddressInfo is one entry of the public get_addresses method. Amounts are
+// decimal strings in MAS, as returned by the API.
+type RPCAddressInfo struct {
+	Address              string               `json:"address"`
+	Thread               uint8                `json:"thread"`
+	FinalBalance         string               `json:"final_balance"`
+	CandidateBalance     string               `json:"candidate_balance"`
+	FinalRollCount       uint64               `json:"final_roll_count"`
+	CandidateRollCount   uint64               `json:"candidate_roll_count"`
+	DeferredCredits      []RPCDeferredCredit  `json:"deferred_credits"`
+	NextBlockDraws       []RPCSlot            `json:"next_block_draws"`
+	NextEndorsementDraws []RPCEndorsementDraw `json:"next_endorsement_draws"`
+	CreatedBlocks        []string             `json:"created_blocks"`
+	CreatedOperations    []string             `json:"created_operations"`
+	CreatedEndorsements  []string             `json:"created_endorsements"`
+	CycleInfos           []RPCCycleInfo       `json:"cycle_infos"`
+}
+
+// RPCStaker is an address and its active roll count, from get_stakers.
+type RPCStaker struct {
+	Address string `json:"address"`
+	Rolls   uint64 `json:"rolls"`
+}
+
+// GetNodeAPIStatus calls get_status on the node's public API.
+func (a *App) GetNodeAPIStatus(serverID string) (*RPCNodeStatus, error) {
+	var status RPCNodeStatus
+	if err := a.callNodeRPCReadOnly(serverID, massaPublicAPIPort, "get_status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetAddresses calls get_addresses on the node's public API.
+func (a *App) GetAddresses(serverID string, addresses []string) ([]RPCAddressInfo, error) {
+	if len(addresses) == 0 {
+		return []RPCAddressInfo{}, nil
+	}
+	var infos []RPCAddressInfo
+	if err := a.callNodeRPCReadOnly(serverID, massaPublicAPIPort, "get_addresses", []interface{}{addresses}, &infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// GetStakers calls get_stakers on the node's public API.
+func (a *App) GetStakers(serverID string) ([]RPCStaker, error) {
+	var pairs [][]json.RawMessage
+	if err := a.callNodeRPCReadOnly(serverID, massaPublicAPIPort, "get_stakers", nil, &pairs); err != nil {
+		return nil, err
+	}
+	stakers := make([]RPCStaker, 0, len(pairs))
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("unexpected get_stakers entry with %d elements", len(pair))
+		}
+		var s RPCStaker
+		if err := json.Unmarshal(pair[0], &s.Address); err != nil {
+			return nil, fmt.Errorf("unexpected get_stakers address: %w", err)
+		}
+		if err := json.Unmarshal(pair[1], &s.Rolls); err != nil {
+			return nil, fmt.Errorf("unexpected get_stakers roll count: %w", err)
+		}
+		stakers = append(stakers, s)
+	}
+	return stakers, nil
+}
+
+// GetStakingAddresses calls node_get_staking_addresses on the node's private API.
+func (a *App) GetStakingAddresses(serverID string) ([]string, error) {
+	var addresses []string
+	if err := a.callNodeRPCReadOnly(serverID, massaPrivateAPIPort, "node_get_staking_addresses", nil, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// AddStakingSecretKeys calls node_add_staking_secret_keys on the node's private API.
+func (a *App) AddStakingSecretKeys(serverID string, secretKeys []string) error {
+	if len(secretKeys) == 0 {
+		return fmt.Errorf("no secret keys given")
+	}
+	return a.callNodeRPC(serverID, massaPrivateAPIPort, "node_add_staking_secret_keys", []interface{}{secretKeys}, nil)
+}
+
+// RemoveStakingAddresses calls node_remove_staking_addresses on the node's private API.
+func (a *App) RemoveStakingAddresses(serverID string, addresses []string) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("no addresses given")
+	}
+	return a.callNodeRPC(serverID, massaPrivateAPIPort, "node_remove_staking_addresses", []interface{}{addresses}, nil)
+}
+
+// StopNodeAPI calls node_stop on the node's private API.
+func (a *App) StopNodeAPI(serverID string) error {
+	return a.callNodeRPC(serverID, massaPrivateAPIPort, "node_stop", nil, nil)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// massaRunnerScript starts massa-node or massa-client without putting the
+// wallet password on their command line. Both binaries only take the password
+// as "-p <password>" or from an interactive prompt, so the runner gives the
+// program a pseudo-terminal with script(1) and types the password into the
+// prompt once the program has switched terminal echo off.
+//
+// Usage: massa-run.sh <binary> [password-file [input-file]]
+//
+// The password file is read and deleted right away; without one the systemd
+// credential is used. The input file (massa-client commands) is sent after the
+// password and deleted as well. Without an input file, keystrokes from the
+// runner's own terminal (the massa_client screen) are passed through once the
+// password has been typed. The program runs in the current directory.
+const massaRunnerScript = `#!/bin/bash
+set -u -o pipefail
+BINARY="$1"
+PASSWORD_FILE="${2:-${CREDENTIALS_DIRECTORY:-/etc/massa-node}/node_password}"
+INPUT_FILE="${3:-}"
+
+# Keep the runner's own stdin for the interactive passthrough.
+exec 4<&0
+
+PASSWORD=""
+IFS= read -r PASSWORD < "${PASSWORD_FILE}" || [ -n "${PASSWORD}" ] || { echo "massa-run: cannot read password" >&2; exit 1; }
+if [ -n "${2:-}" ]; then
+    rm -f "${PASSWORD_FILE}"
+    rmdir "$(dirname "${PASSWORD_FILE}")" 2>/dev/null
+fi
+
+PRIVATE_DIR=$(mktemp -d)
+FIFO="${PRIVATE_DIR}/input"
+TRANSCRIPT="${PRIVATE_DIR}/transcript"
+mkfifo -m 600 "${FIFO}"
+exec 3<>"${FIFO}"
+
+# Count password prompts shown so far. Only the first 64 KiB of output is
+# kept, which is plenty to see the prompts of a starting program.
+prompt_count() {
+    grep -o -i 'password' "${TRANSCRIPT}" 2>/dev/null | wc -l
+}
+
+# A prompt is waiting when the program's terminal is in canonical mode with
+# echo off, which is what dialoguer's password prompt does. Anything typed
+# while echo is off is never written back to the output. Only our own program
+# is looked at, never another node or client on the host: script(1) is a child
+# of this runner ($$ in the feeder too) and runs the program directly or
+# through a shell.
+prompt_waiting() {
+    local pid tty parents
+    parents=$(pgrep -d, -x -P $$ script) || return 1
+    parents="${parents},$(pgrep -d, -P "${parents}")"
+    pid=$(pgrep -n -x -P "${parents%,}" "${BINARY}") || return 1
+    tty=$(readlink "/proc/${pid}/fd/0") || return 1
+    stty -F "${tty}" -a 2>/dev/null | grep -qw -- '-echo' || return 1
+    stty -F "${tty}" -a 2>/dev/null | grep -qw -- 'icanon'
+}
+
+feed() {
+    local fed=0 quiet=0 prompts
+    for _ in $(seq 1 300); do
+        sleep 0.2
+        if prompt_waiting; then
+            prompts=$(prompt_count)
+            # A new wallet asks twice (password and confirmation).
+            if [ "${prompts}" -gt "${fed}" ]; then
+                printf '%s\r' "${PASSWORD}" >&3
+                fed=$((fed + 1))
+                quiet=0
+                continue
+            fi
+        fi
+        if [ "${fed}" -gt 0 ]; then
+            quiet=$((quiet + 1))
+            [ "${quiet}" -ge 15 ] && break
+        fi
+    done
+    PASSWORD=""
+    if [ -n "${INPUT_FILE}" ]; then
+        tr '\n' '\r' < "${INPUT_FILE}" >&3
+        rm -f "${INPUT_FILE}"
+        rmdir "$(dirname "${INPUT_FILE}")" 2>/dev/null
+        rm -rf "${PRIVATE_DIR}"
+    elif [ -t 4 ]; then
+        rm -rf "${PRIVATE_DIR}"
+        stty raw -echo <&4
+        cat <&4 >&3
+    else
+        rm -rf "${PRIVATE_DIR}"
+    fi
+}
+
+feed >/dev/null 2>&1 &
+FEEDER=$!
+PASSWORD=""
+script -qfec "./${BINARY}" /dev/null <&3 | tee -p >(stdbuf -o0 head -c 65536 > "${TRANSCRIPT}")
+STATUS=$?
+pkill -P "${FEEDER}" 2>/dev/null
+kill "${FEEDER}" 2>/dev/null
+[ -t 4 ] && stty sane <&4
+rm -rf "${PRIVATE_DIR}"
+exit "${STATUS}"
+`
+
+// installMassaRunner writes massaRunnerScript to the server, owned by the
+// node's run-as user.
+func (a *App) installMassaRunner(serverID string) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	layout := srv.getLayout()
+	runner := layout.runnerPath()
+	cmd := shellJoin("sh", "-c", `mkdir -p "$1" && cat > "$2" && chmod 700 "$2" && if [ -n "$3" ]; then chown "$3:" "$2"; fi`,
+		"sh", layout.InstallDir, runner, layout.RunAsUser)
+	if _, err := a.runCommand(serverID, cmd, strings.NewReader(massaRunnerScript)); err != nil {
+		return fmt.Errorf("failed to install %s: %w", runner, err)
+	}
+	return nil
+}
+
+// createSecretDir creates a private (0700) directory on the server and writes
+// each of files into it with mode 0600, owned by the node's run-as user so
+// the runner can read and delete them. Contents travel over the session's
+// stdin, never on a command line. Runners delete the files they read and the
+// directory once it is empty; everything else is up to the caller, with
+// removeSecretDir or removeSecretFile.
+func (a *App) createSecretDir(serverID string, files map[string]string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "", err
+	}
+	output, err := a.RunCommand(serverID, "umask 077 && mktemp -d /tmp/massa-secrets.XXXXXXXX")
+	if err != nil {
+		return "", fmt.Errorf("failed to create private directory: %w", err)
+	}
+	dir := strings.TrimSpace(output)
+	if !strings.HasPrefix(dir, "/tmp/massa-secrets.") {
+		return "", fmt.Errorf("unexpected mktemp output %q", output)
+	}
+	for name, content := range files {
+		file := path.Join(dir, name)
+		cmd := shellJoin("sh", "-c", `umask 077 && cat > "$1"`, "sh", file)
+		if _, err := a.runCommand(serverID, cmd, strings.NewReader(content)); err != nil {
+			a.removeSecretDir(serverID, dir)
+			return "", fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if user := srv.getLayout().RunAsUser; user != "" && user != srv.user {
+		if _, err := a.runArgs(serverID, []string{"chown", "-R", user + ":", dir}, nil); err != nil {
+			a.removeSecretDir(serverID, dir)
+			return "", fmt.Errorf("failed to hand the private directory to %s: %w", user, err)
+		}
+	}
+	return dir, nil
+}
+
+// removeSecretDir deletes a directory created by createSecretDir.
+func (a *App) removeSecretDir(serverID string, dir string) {
+	if _, err := a.runArgs(serverID, []string{"rm", "-rf", dir}, nil); err != nil {
+		fmt.Printf("Warning: failed to remove %s on %s: %v\n", dir, serverID, err)
+	}
+}
+
+// removeSecretFile deletes a file from a directory created by createSecretDir
+// that no runner is going to read, and the directory if nothing is left in it.
+// Files handed to a runner in a detached screen are left alone: the runner may
+// not have read them yet.
+func (a *App) removeSecretFile(serverID string, file string) {
+	cmd := shellJoin("sh", "-c", `rm -f "$1" && { rmdir "$2" 2>/dev/null || true; }`, "sh", file, path.Dir(file))
+	if _, err := a.RunCommand(serverID, cmd); err != nil {
+		fmt.Printf("Warning: failed to remove %s on %s: %v\n", file, serverID, err)
+	}
+}
+
+// minGuardedSecretLen is the shortest secret the leak guard looks for. Shorter
+// values would match ordinary words and paths in commands.
+const minGuardedSecretLen = 6
+
+// validNodePassword checks a node password before it is stored or used. A
+// shorter password could not be told apart from ordinary command words, so the
+// leak guard would have to leave it unchecked.
+func validNodePassword(password string) error {
+	if len(password) < minGuardedSecretLen {
+		return fmt.Errorf("node password must be at least %d characters", minGuardedSecretLen)
+	}
+	return nil
+}
+
+// commandHasWord reports whether value is one of command's shell words, or the
+// value of a "name=value" word. Words that are scripts themselves (sh -c,
+// bash -c, screen ... -c) are searched as well. Unlike a substring match, a
+// password that happens to be part of a path or a longer word does not count.
+func commandHasWord(command string, value string) bool {
+	for _, word := range shellWords(command) {
+		if word == value {
+			return true
+		}
+		if _, v, ok := strings.Cut(word, "="); ok && v == value {
+			return true
+		}
+		if word != command && strings.ContainsAny(word, " \t\n;&|<>()'\"\\") && commandHasWord(word, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretGuard remembers secret values handled by the app so commands and log
+// lines can be checked for them before they leave the process.
+type secretGuard struct {
+	mu      sync.Mutex
+	secrets map[string]int // Value -> number of holders
+}
+
+func newSecretGuard() *secretGuard {
+	return &secretGuard{secrets: make(map[string]int)}
+}
+
+// hold registers secret and returns a function that unregisters it.
+func (g *secretGuard) hold(secret string) func() {
+	if len(secret) < minGuardedSecretLen {
+		return func() {}
+	}
+	g.mu.Lock()
+	g.secrets[secret]++
+	g.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			if g.secrets[secret]--; g.secrets[secret] <= 0 {
+				delete(g.secrets, secret)
+			}
+			g.mu.Unlock()
+		})
+	}
+}
+
+// contains reports whether text includes any registered secret.
+func (g *secretGuard) contains(text string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for secret := range g.secrets {
+		if strings.Contains(text, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact replaces registered secrets in text, for log output.
+func (g *secretGuard) redact(text string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for secret := range g.secrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	return text
+}
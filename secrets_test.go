@@ -0,0 +1,432 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	fakeServerID     = "test"
+	fakeSecretDir    = "/tmp/massa-secrets.test1234"
+	testNodePassword = "n0de-Pa55word-7781"
+	testSecretKey    = "S12XuWmm5jULpJGXBnkeBsuiNmsGi2F4rMiTvriCzENxBR4Ev7vd"
+)
+
+func TestSetupKeepsSecretsOffCommandLines(t *testing.T) {
+	a, fake := newFakeServerApp(t, nil)
+	if err := a.checksums.set(defaultMassaVersion, strings.Repeat("ab", 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	var log string
+	stdout := captureStdout(t, func() {
+		var err error
+		log, err = a.SetupAndRunMassaComponents(fakeServerID, testNodePassword, "93.184.216.34", false)
+		if err != nil {
+			t.Errorf("setup: %v\n%s", err, log)
+		}
+	})
+
+	commands := fake.commands()
+	var script, scriptRun string
+	for _, c := range commands {
+		assertNoSecrets(t, "command", c.command)
+		if strings.Contains(c.command, "| base64 -d >") {
+			encoded := strings.SplitN(strings.SplitN(c.command, "echo '", 2)[1], "'", 2)[0]
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("setup script is not valid base64: %v", err)
+			}
+			script = string(decoded)
+		}
+		if strings.HasPrefix(c.command, shellQuote(NodeLayout{InstallDir: defaultInstallDir}.setupScriptPath())+" ") {
+			scriptRun = c.command
+		}
+	}
+	if script == "" {
+		t.Fatal("setup script was not written")
+	}
+	assertNoSecrets(t, "setup script", script)
+	if !strings.Contains(script, `NODE_PASSWORD_DIR="$1"`) {
+		t.Error("setup script does not read the password directory argument")
+	}
+	if !strings.HasPrefix(scriptRun, shellJoin(NodeLayout{InstallDir: defaultInstallDir}.setupScriptPath(), fakeSecretDir)+" ") {
+		t.Errorf("setup script run as %q, want the password directory as first argument", scriptRun)
+	}
+	assertSecretsOnlyInPrivateFiles(t, commands, testNodePassword)
+	assertNoSecrets(t, "setup log", log)
+	assertNoSecrets(t, "log output", stdout)
+}
+
+func TestStartNodeKeepsSecretsOffCommandLines(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the node to start")
+	}
+	a, fake := newFakeServerApp(t, nil)
+
+	var output string
+	stdout := captureStdout(t, func() {
+		var err error
+		output, err = a.StartMassaNode(fakeServerID, testNodePassword)
+		if err != nil {
+			t.Errorf("start: %v\n%s", err, output)
+		}
+	})
+
+	commands := fake.commands()
+	started := false
+	for _, c := range commands {
+		assertNoSecrets(t, "command", c.command)
+		if strings.Contains(c.command, "screen -dmS massa_node") {
+			started = true
+			if !strings.Contains(c.command, fakeSecretDir+"/node_password") {
+				t.Errorf("node started without the password file: %q", c.command)
+			}
+		}
+	}
+	if !started {
+		t.Error("node was not started")
+	}
+	for _, c := range commands {
+		if c.command == shellJoin("rm", "-rf", fakeSecretDir) {
+			t.Error("password directory removed while the node screen may not have read it yet")
+		}
+	}
+	assertSecretsOnlyInPrivateFiles(t, commands, testNodePassword)
+	assertNoSecrets(t, "output", output)
+	assertNoSecrets(t, "log output", stdout)
+}
+
+func TestImportWalletKeyKeepsSecretsOffCommandLines(t *testing.T) {
+	// The client echoes the command it was given, secret key included.
+	a, fake := newFakeServerApp(t, func(command string) (string, uint32, bool) {
+		if strings.Contains(command, "massa-run.sh") {
+			return "Enter wallet password:\nwallet_add_secret_keys " + testSecretKey + "\nKeys successfully added!\nexit\n", 0, true
+		}
+		return "", 0, false
+	})
+	srv, _ := a.server(fakeServerID)
+	srv.setNodePassword(testNodePassword)
+
+	var output string
+	stdout := captureStdout(t, func() {
+		var err error
+		output, err = a.ImportWalletKey(fakeServerID, testSecretKey)
+		if err != nil {
+			t.Errorf("import: %v\n%s", err, output)
+		}
+	})
+
+	commands := fake.commands()
+	for _, c := range commands {
+		assertNoSecrets(t, "command", c.command)
+	}
+	assertSecretsOnlyInPrivateFiles(t, commands, testNodePassword)
+	assertSecretsOnlyInPrivateFiles(t, commands, testSecretKey)
+	if !strings.Contains(output, "Keys successfully added!") {
+		t.Errorf("output = %q, want the client's reply", output)
+	}
+	assertNoSecrets(t, "output", output)
+	assertNoSecrets(t, "log output", stdout)
+}
+
+func TestCommandOutputIsRedactedInLogs(t *testing.T) {
+	a, _ := newFakeServerApp(t, func(command string) (string, uint32, bool) {
+		if command == "cat creds" {
+			return "password=" + testNodePassword + "\nkey=" + testSecretKey + "\n", 0, true
+		}
+		return "", 0, false
+	})
+	srv, _ := a.server(fakeServerID)
+	srv.setNodePassword(testNodePassword)
+	release := a.secrets.hold(testSecretKey)
+	defer release()
+
+	stdout := captureStdout(t, func() {
+		if _, err := a.RunCommand(fakeServerID, "cat creds"); err != nil {
+			t.Errorf("RunCommand: %v", err)
+		}
+	})
+	assertNoSecrets(t, "log output", stdout)
+	if !strings.Contains(stdout, "[REDACTED]") {
+		t.Errorf("log output does not show the redaction:\n%s", stdout)
+	}
+}
+
+func TestRunArgsRefusesSecrets(t *testing.T) {
+	a, fake := newFakeServerApp(t, nil)
+	srv, _ := a.server(fakeServerID)
+	srv.setNodePassword(testNodePassword)
+	release := a.secrets.hold(testSecretKey)
+	defer release()
+
+	stdout := captureStdout(t, func() {
+		for _, argv := range [][]string{
+			{"massa-node", "-p", testNodePassword},
+			{"sh", "-c", "echo " + testNodePassword + " > /tmp/pw"},
+			{"massa-client", "wallet_add_secret_keys", testSecretKey},
+		} {
+			if _, err := a.runArgs(fakeServerID, argv, nil); err == nil {
+				t.Errorf("runArgs(%q) succeeded, want it refused", argv)
+			}
+		}
+	})
+	if commands := fake.commands(); len(commands) != 0 {
+		t.Errorf("refused commands reached the server: %+v", commands)
+	}
+	assertNoSecrets(t, "log output", stdout)
+}
+
+func TestRunCommandMatchesNodePasswordAsWord(t *testing.T) {
+	a, fake := newFakeServerApp(t, nil)
+	srv, _ := a.server(fakeServerID)
+	srv.setNodePassword("massa_node")
+
+	captureStdout(t, func() {
+		for _, command := range []string{
+			"cd /root/massa_node/massa && ls",
+			"pgrep -f massa_node_old || true",
+			shellJoin("tail", "-n", "20", "/root/massa/massa_node.log"),
+		} {
+			if _, err := a.RunCommand(fakeServerID, command); err != nil {
+				t.Errorf("RunCommand(%q): %v", command, err)
+			}
+		}
+		for _, command := range []string{
+			"echo massa_node",
+			"printf '%s' \"massa_node\">/tmp/pw",
+			shellJoin("sh", "-c", "echo massa_node | tee /tmp/pw"),
+			"env PASSWORD=massa_node true",
+		} {
+			if _, err := a.RunCommand(fakeServerID, command); err == nil {
+				t.Errorf("RunCommand(%q) succeeded, want it refused", command)
+			}
+		}
+	})
+	if commands := fake.commands(); len(commands) != 3 {
+		t.Errorf("%d commands reached the server, want 3: %+v", len(commands), commands)
+	}
+}
+
+func TestShortNodePasswordIsRejected(t *testing.T) {
+	a, fake := newFakeServerApp(t, nil)
+	captureStdout(t, func() {
+		if _, err := a.StartMassaNode(fakeServerID, "abc12"); err == nil {
+			t.Error("StartMassaNode accepted a 5 character password")
+		}
+		if _, err := a.SetupAndRunMassaComponents(fakeServerID, "abc12", "93.184.216.34", false); err == nil {
+			t.Error("SetupAndRunMassaComponents accepted a 5 character password")
+		}
+	})
+	if commands := fake.commands(); len(commands) != 0 {
+		t.Errorf("commands reached the server: %+v", commands)
+	}
+	srv, _ := a.server(fakeServerID)
+	if pw := srv.getNodePassword(); pw != "" {
+		t.Errorf("node password %q was stored", pw)
+	}
+}
+
+// assertNoSecrets fails the test if text contains the node password or the
+// secret key.
+func assertNoSecrets(t *testing.T, what string, text string) {
+	t.Helper()
+	for name, secret := range map[string]string{"node password": testNodePassword, "secret key": testSecretKey} {
+		if strings.Contains(text, secret) {
+			t.Errorf("%s contains the %s:\n%s", what, name, text)
+		}
+	}
+}
+
+// assertSecretsOnlyInPrivateFiles checks that secret was only sent over stdin
+// to a file in the private directory, and was sent at least once.
+func assertSecretsOnlyInPrivateFiles(t *testing.T, commands []fakeCommand, secret string) {
+	t.Helper()
+	sent := false
+	for _, c := range commands {
+		if !strings.Contains(c.stdin, secret) {
+			continue
+		}
+		sent = true
+		if !strings.Contains(c.command, fakeSecretDir+"/") {
+			t.Errorf("secret sent to %q, outside the private directory", c.command)
+		}
+	}
+	if !sent {
+		t.Error("secret never reached the server")
+	}
+}
+
+// captureStdout returns what fn prints to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	os.Stdout = stdout
+	return <-done
+}
+
+// fakeCommand is one command run on a fakeSSHServer.
+type fakeCommand struct {
+	command string
+	stdin   string
+}
+
+// fakeSSHServer accepts any client and answers exec requests without running
+// anything, recording each command and its stdin.
+type fakeSSHServer struct {
+	respond func(command string) (string, uint32, bool)
+
+	mu  sync.Mutex
+	log []fakeCommand
+}
+
+// newFakeServerApp returns an App connected to a fakeSSHServer as
+// fakeServerID. respond may answer commands first; the rest get the replies a
+// server with an installed, stopped node would give.
+func newFakeServerApp(t *testing.T, respond func(command string) (string, uint32, bool)) (*App, *fakeSSHServer) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	fake := &fakeSSHServer{respond: respond}
+	addr := fake.start(t)
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{User: "root", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	a := NewApp()
+	srv := newServerConn(fakeServerID, host, port, "root", AuthOptions{}, client)
+	a.addServer(srv)
+	t.Cleanup(func() { srv.close() })
+	return a, fake
+}
+
+func (s *fakeSSHServer) start(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (s *fakeSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.session(channel, requests)
+	}
+}
+
+func (s *fakeSSHServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			return
+		}
+		req.Reply(true, nil)
+		stdin, _ := io.ReadAll(channel)
+		s.mu.Lock()
+		s.log = append(s.log, fakeCommand{command: payload.Command, stdin: string(stdin)})
+		s.mu.Unlock()
+
+		output, status := s.reply(payload.Command)
+		io.WriteString(channel, output)
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+func (s *fakeSSHServer) reply(command string) (string, uint32) {
+	if s.respond != nil {
+		if output, status, ok := s.respond(command); ok {
+			return output, status
+		}
+	}
+	switch {
+	case strings.Contains(command, "mktemp -d /tmp/massa-secrets."):
+		return fakeSecretDir + "\n", 0
+	case strings.Contains(command, "-name massa-client -type d"):
+		return NodeLayout{InstallDir: defaultInstallDir}.clientDir() + "\n", 0
+	case command == "uname -m":
+		return "x86_64\n", 0
+	case strings.Contains(command, "echo 'INSTALLED'"):
+		return "INSTALLED\n", 0
+	case strings.Contains(command, "screen -list"):
+		return "No Sockets found.\n", 1
+	}
+	return "", 0
+}
+
+func (s *fakeSSHServer) commands() []fakeCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeCommand(nil), s.log...)
+}
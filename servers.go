@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serverConn is a live SSH connection to one managed server. The underlying
+// client is replaced by the connection supervisor when the link drops, so it
+// must always be fetched through sshClient.
+type serverConn struct {
+	id   string
+	host string
+	port int
+	user string
+	auth AuthOptions // Kept to reconnect without asking the user again
+
+	mu           sync.Mutex
+	client       *ssh.Client
+	state        string // One of the connState* values
+	nodePassword string // Remembered for massa-client calls after setup/start
+	serviceMode  string // serviceModeScreen or serviceModeSystemd
+	massaVersion string // Release installed by setup, defaultMassaVersion if empty
+	layout       NodeLayout
+
+	stop     chan struct{} // Closed when the server is disconnected
+	stopOnce sync.Once
+}
+
+func newServerConn(id string, host string, port int, user string, auth AuthOptions, client *ssh.Client) *serverConn {
+	return &serverConn{
+		id:     id,
+		host:   host,
+		port:   port,
+		user:   user,
+		auth:   auth,
+		client: client,
+		state:  connStateConnected,
+		stop:   make(chan struct{}),
+	}
+}
+
+// sshClient returns the current SSH client, or errConnectionLost while the
+// supervisor is reconnecting.
+func (s *serverConn) sshClient() (*ssh.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != connStateConnected || s.client == nil {
+		return nil, fmt.Errorf("%w to server %q", errConnectionLost, s.id)
+	}
+	return s.client, nil
+}
+
+// setClient installs a (re)connected client. If the connection was stopped in
+// the meantime, client is closed instead and false is returned.
+func (s *serverConn) setClient(client *ssh.Client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped() {
+		client.Close()
+		return false
+	}
+	s.client = client
+	s.state = connStateConnected
+	return true
+}
+
+// markLost flags the connection as dead and returns the client that was in use.
+func (s *serverConn) markLost() *ssh.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client := s.client
+	s.state = connStateReconnecting
+	return client
+}
+
+func (s *serverConn) getState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// close stops the supervisor and closes the current client.
+func (s *serverConn) close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.mu.Lock()
+	client := s.client
+	s.client = nil
+	s.state = connStateDisconnected
+	s.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+func (s *serverConn) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// setNodePassword remembers the node password for later massa-client commands.
+func (s *serverConn) setNodePassword(password string) {
+	s.mu.Lock()
+	s.nodePassword = password
+	s.mu.Unlock()
+}
+
+// getNodePassword returns the node password last used to set up or start the node.
+func (s *serverConn) getNodePassword() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nodePassword
+}
+
+// setServiceMode records how the node on this server is run.
+func (s *serverConn) setServiceMode(mode string) {
+	s.mu.Lock()
+	s.serviceMode = mode
+	s.mu.Unlock()
+}
+
+// getServiceMode returns how the node on this server is run, defaulting to screen.
+func (s *serverConn) getServiceMode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.serviceMode == "" {
+		return serviceModeScreen
+	}
+	return s.serviceMode
+}
+
+// setMassaVersion records the Massa release to install on this server.
+func (s *serverConn) setMassaVersion(version string) {
+	s.mu.Lock()
+	s.massaVersion = version
+	s.mu.Unlock()
+}
+
+// getMassaVersion returns the Massa release to install, defaulting to defaultMassaVersion.
+func (s *serverConn) getMassaVersion() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.massaVersion == "" {
+		return defaultMassaVersion
+	}
+	return s.massaVersion
+}
+
+// setLayout records where the node lives on this server. layout must have
+// been normalised by validNodeLayout.
+func (s *serverConn) setLayout(layout NodeLayout) {
+	s.mu.Lock()
+	s.layout = layout
+	s.mu.Unlock()
+}
+
+// getLayout returns where the node lives on this server, defaulting to defaultInstallDir run by the SSH user.
+func (s *serverConn) getLayout() NodeLayout {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	layout := s.layout
+	if layout.InstallDir == "" {
+		layout.InstallDir = defaultInstallDir
+	}
+	return layout
+}
+
+// inheritState copies the node state remembered by previous into s.
+func (s *serverConn) inheritState(previous *serverConn) {
+	previous.mu.Lock()
+	password, mode, version, layout := previous.nodePassword, previous.serviceMode, previous.massaVersion, previous.layout
+	previous.mu.Unlock()
+
+	s.mu.Lock()
+	s.nodePassword, s.serviceMode, s.massaVersion, s.layout = password, mode, version, layout
+	s.mu.Unlock()
+}
+
+// ServerInfo describes a connected server for the frontend.
+type ServerInfo struct {
+	ID    string `json:"id"`
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+	User  string `json:"user"`
+	State string `json:"state"` // "connected" or "reconnecting"
+}
+
+// FleetResult is the outcome of a fleet-wide call on a single server.
+type FleetResult struct {
+	ServerID string `json:"serverId"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+}
+
+// server returns the connection registered under serverID.
+func (a *App) server(serverID string) (*serverConn, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	srv, ok := a.servers[serverID]
+	if !ok {
+		return nil, fmt.Errorf("no active SSH connection for server %q", serverID)
+	}
+	return srv, nil
+}
+
+// addServer registers srv, returning the connection it replaced, if any. srv
+// takes over the node password, service mode, version and layout of the
+// connection it replaces, so reconnecting does not lose them.
+func (a *App) addServer(srv *serverConn) *serverConn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	previous := a.servers[srv.id]
+	if previous != nil {
+		srv.inheritState(previous)
+	}
+	a.servers[srv.id] = srv
+	return previous
+}
+
+// removeServer unregisters serverID and returns its connection, if it existed.
+func (a *App) removeServer(serverID string) *serverConn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	srv, ok := a.servers[serverID]
+	if !ok {
+		return nil
+	}
+	delete(a.servers, serverID)
+	return srv
+}
+
+// serverIDs returns the IDs of all connected servers in a stable order.
+func (a *App) serverIDs() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	ids := make([]string, 0, len(a.servers))
+	for id := range a.servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// closeAllServers closes every connection, used when the app shuts down.
+func (a *App) closeAllServers() {
+	a.logStreams.stopServer("")
+	a.tunnels.closeServer("")
+	a.stakingMonitors.stopServer("")
+	for _, id := range a.serverIDs() {
+		if srv := a.removeServer(id); srv != nil {
+			if err := srv.close(); err != nil {
+				fmt.Printf("Error closing SSH connection to %s: %v\n", id, err)
+			}
+		}
+	}
+}
+
+// ListConnectedServers returns every server with an active SSH connection.
+func (a *App) ListConnectedServers() []ServerInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	servers := make([]ServerInfo, 0, len(a.servers))
+	for _, srv := range a.servers {
+		servers = append(servers, ServerInfo{ID: srv.id, Host: srv.host, Port: srv.port, User: srv.user, State: srv.getState()})
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers
+}
+
+// fanOut runs fn concurrently for every connected server and collects the results,
+// ordered by server ID.
+func (a *App) fanOut(fn func(serverID string) (string, error)) []FleetResult {
+	ids := a.serverIDs()
+	results := make([]FleetResult, len(ids))
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			output, err := fn(id)
+			results[i] = FleetResult{ServerID: id, Output: output}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, id)
+	}
+	wg.Wait()
+	return results
+}
+
+// RunFleetCommand runs command on every connected server.
+func (a *App) RunFleetCommand(command string) []FleetResult {
+	fmt.Printf("Running command on all servers: %s\n", command)
+	return a.fanOut(func(serverID string) (string, error) {
+		return a.RunCommand(serverID, command)
+	})
+}
+
+// GetFleetServerStats collects GetServerStats from every connected server.
+func (a *App) GetFleetServerStats() []FleetResult {
+	return a.fanOut(a.GetServerStats)
+}
+
+// CheckFleetNodeStatus collects CheckMassaNodeStatus from every connected server.
+func (a *App) CheckFleetNodeStatus() []FleetResult {
+	return a.fanOut(a.CheckMassaNodeStatus)
+}
+
+// CheckFleetNodeInstallation collects CheckMassaNodeInstallation from every connected server.
+func (a *App) CheckFleetNodeInstallation() []FleetResult {
+	return a.fanOut(a.CheckMassaNodeInstallation)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How the Massa node process is run on a server. Screen mode is the historical
+// behaviour; systemd mode installs a unit so the node survives reboots and logs
+// go to journald.
+const (
+	serviceModeScreen  = "screen"
+	serviceModeSystemd = "systemd"
+)
+
+const (
+	massaServiceName = "massa-node.service"
+	// massaCredentialsDir holds the node password, readable by root only. The
+	// unit loads it with LoadCredential= so it never appears on a command line
+	// visible to other users or in the unit file itself.
+	massaCredentialsDir  = "/etc/massa-node"
+	massaCredentialsFile = massaCredentialsDir + "/node_password"
+	massaServiceUnitPath = "/etc/systemd/system/" + massaServiceName
+)
+
+// massaServiceUnit is the systemd unit for the node. The placeholders are the
+// run-as user, the node directory and the runner, which types the password
+// from $CREDENTIALS_DIRECTORY (or the credentials file on systemd versions
+// without LoadCredential support) into the node's prompt, so it never appears
+// on the node's command line.
+const massaServiceUnit = `[Unit]
+Description=Massa Node
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=%s
+WorkingDirectory=%s
+LoadCredential=node_password:` + massaCredentialsFile + `
+ExecStart=%s massa-node
+Restart=on-failure
+RestartSec=10
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// validServiceMode normalises mode, treating an empty value as screen mode.
+func validServiceMode(mode string) (string, error) {
+	switch mode {
+	case "", serviceModeScreen:
+		return serviceModeScreen, nil
+	case serviceModeSystemd:
+		return serviceModeSystemd, nil
+	default:
+		return "", fmt.Errorf("unknown service mode %q (expected %q or %q)", mode, serviceModeScreen, serviceModeSystemd)
+	}
+}
+
+// SetServiceMode selects how the node on serverID is started, stopped and
+// inspected: "screen" (default) or "systemd". It does not touch the server; run
+// SetupAndRunMassaComponents or InstallMassaService to apply it.
+func (a *App) SetServiceMode(serverID string, mode string) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	mode, err = validServiceMode(mode)
+	if err != nil {
+		return err
+	}
+	srv.setServiceMode(mode)
+	return nil
+}
+
+// InstallMassaService installs (or refreshes) the systemd unit for an already
+// installed node, stops any screen sessions running it and switches the server
+// to systemd mode.
+func (a *App) InstallMassaService(serverID string, nodePassword string) (string, error) {
+	fmt.Println("InstallMassaService called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+	if nodePassword == "" {
+		return "Error: Node password is required to install the Massa service.", fmt.Errorf("node password is required")
+	}
+	if err := validNodePassword(nodePassword); err != nil {
+		return fmt.Sprintf("Error: %v", err), err
+	}
+
+	var logBuffer strings.Builder
+	logBuffer.WriteString("Stopping screen sessions before switching to systemd...\n")
+	if _, err := a.RunCommand(serverID, srv.asNodeUser("screen -S massa_node -X quit; screen -S massa_client -X quit; true")); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("Warning: failed to stop screen sessions: %v\n", err))
+	}
+
+	serviceLog, err := a.installMassaService(serverID, nodePassword)
+	logBuffer.WriteString(serviceLog)
+	if err != nil {
+		return logBuffer.String(), err
+	}
+	srv.setNodePassword(nodePassword)
+	srv.setServiceMode(serviceModeSystemd)
+	return logBuffer.String(), nil
+}
+
+// installMassaService writes the credentials file and unit, then enables and
+// (re)starts the service and waits for it to come up.
+func (a *App) installMassaService(serverID string, nodePassword string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+	layout := srv.getLayout()
+	expectedNodeDir := layout.nodeDir()
+	unitUser := layout.RunAsUser
+	if unitUser == "" {
+		unitUser = srv.user
+	}
+	var logBuffer strings.Builder
+
+	if _, err := a.RunCommand(serverID, "command -v systemctl >/dev/null"); err != nil {
+		logBuffer.WriteString("ERROR: systemctl not found on the server.\n")
+		return logBuffer.String(), fmt.Errorf("systemd is not available on this server")
+	}
+
+	// The password goes over stdin so it never shows up in argv or shell history.
+	// /etc and systemctl need root, so these commands go through sudo for other
+	// SSH users.
+	writeCredsCmd := fmt.Sprintf("umask 077 && mkdir -p %s && chmod 700 %s && cat > %s && chmod 600 %s",
+		massaCredentialsDir, massaCredentialsDir, massaCredentialsFile, massaCredentialsFile)
+	if _, err := a.runCommand(serverID, srv.asRoot(writeCredsCmd), strings.NewReader(nodePassword)); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: failed to write credentials file: %v\n", err))
+		return logBuffer.String(), fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	logBuffer.WriteString(fmt.Sprintf("Wrote node password to %s (mode 0600).\n", massaCredentialsFile))
+
+	if err := a.installMassaRunner(serverID); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: %v\n", err))
+		return logBuffer.String(), err
+	}
+
+	if err := a.chownToNodeUser(serverID); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: %v\n", err))
+		return logBuffer.String(), err
+	}
+
+	unit := fmt.Sprintf(massaServiceUnit, unitUser, expectedNodeDir, layout.runnerPath())
+	writeUnitCmd := fmt.Sprintf("cat > %s && chmod 644 %s", massaServiceUnitPath, massaServiceUnitPath)
+	if _, err := a.runCommand(serverID, srv.asRoot(writeUnitCmd), strings.NewReader(unit)); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: failed to write unit file: %v\n", err))
+		return logBuffer.String(), fmt.Errorf("failed to write unit file: %w", err)
+	}
+	logBuffer.WriteString(fmt.Sprintf("Wrote %s.\n", massaServiceUnitPath))
+
+	enableCmd := shellJoin("chmod", "+x", expectedNodeDir+"/massa-node") +
+		fmt.Sprintf(" && systemctl daemon-reload && systemctl enable %s && systemctl restart %s", massaServiceName, massaServiceName)
+	output, err := a.RunCommand(serverID, srv.asRoot(enableCmd))
+	if output != "" {
+		logBuffer.WriteString(output + "\n")
+	}
+	if err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: failed to enable or start %s: %v\n", massaServiceName, err))
+		return logBuffer.String(), fmt.Errorf("failed to start %s: %w", massaServiceName, err)
+	}
+
+	return a.waitForMassaService(serverID, &logBuffer)
+}
+
+// waitForMassaService gives the unit a few seconds to settle and reports whether it stayed up.
+func (a *App) waitForMassaService(serverID string, logBuffer *strings.Builder) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return logBuffer.String(), err
+	}
+	logBuffer.WriteString(fmt.Sprintf("%s started. Waiting to verify...\n", massaServiceName))
+	time.Sleep(5 * time.Second)
+
+	if _, err := a.RunCommand(serverID, srv.asRoot("systemctl is-active --quiet "+massaServiceName)); err != nil {
+		logBuffer.WriteString(fmt.Sprintf("ERROR: %s is not active. Recent journal entries:\n", massaServiceName))
+		journal, _ := a.RunCommand(serverID, srv.asRoot(fmt.Sprintf("journalctl -u %s -n 30 --no-pager -o cat", massaServiceName)))
+		logBuffer.WriteString(journal + "\n")
+		return logBuffer.String(), fmt.Errorf("%s failed to start", massaServiceName)
+	}
+	logBuffer.WriteString(fmt.Sprintf("SUCCESS: Massa Node systemd service %s is running.\n", massaServiceName))
+	return logBuffer.String(), nil
+}
+
+// startMassaService is StartMassaNode for systemd mode.
+func (a *App) startMassaService(serverID string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+	var logBuffer strings.Builder
+	if _, err := a.RunCommand(serverID, srv.asRoot("systemctl is-active --quiet "+massaServiceName)); err == nil {
+		logBuffer.WriteString(fmt.Sprintf("%s is already running. No need to start.\n", massaServiceName))
+		return logBuffer.String(), nil
+	}
+	if _, err := a.RunCommand(serverID, srv.asRoot("test -f "+massaServiceUnitPath)); err != nil {
+		return "Error: Massa systemd service is not installed. Please install it first.", fmt.Errorf("%s is not installed", massaServiceName)
+	}
+
+	logBuffer.WriteString(fmt.Sprintf("Starting %s...\n", massaServiceName))
+	if _, err := a.RunCommand(serverID, srv.asRoot("systemctl start "+massaServiceName)); err != nil {
+		errMsg := fmt.Sprintf("Failed to start %s: %v", massaServiceName, err)
+		logBuffer.WriteString(errMsg + "\n")
+		return logBuffer.String(), fmt.Errorf(errMsg)
+	}
+	return a.waitForMassaService(serverID, &logBuffer)
+}
+
+// massaServiceLogs is GetMassaNodeLogs for systemd mode.
+func (a *App) massaServiceLogs(serverID string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "Error: No active SSH connection.", err
+	}
+	output, err := a.runReadOnlyCommand(serverID, srv.asRoot(fmt.Sprintf("journalctl -u %s -n 500 --no-pager -o cat", massaServiceName)))
+	if err != nil {
+		fmt.Printf("Error fetching Massa node journal: %v\n", err)
+		return output, err
+	}
+	if output == "" {
+		return fmt.Sprintf("No journal entries found for %s.", massaServiceName), nil
+	}
+	return output, nil
+}
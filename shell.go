@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// shellQuote quotes s as a single word for POSIX sh. Everything is wrapped in
+// single quotes, the only character that cannot appear inside them being the
+// single quote itself, which is closed, escaped and reopened.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, needsShellQuoting) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// needsShellQuoting reports whether r is anything other than characters that
+// are always literal in sh words.
+func needsShellQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("@%+=:,./-_", r):
+		return false
+	}
+	return true
+}
+
+// shellJoin quotes each element of argv and joins them into one command line.
+func shellJoin(argv ...string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellWords splits a command line into words the way sh would, removing
+// quotes and backslashes. Operators (;, &, |, <, >, parentheses) end a word.
+// It is only used to inspect commands, so expansions are left as they are.
+func shellWords(command string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				end = len(command) - i - 1
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			for i++; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(command[i])
+			}
+		case c == '\\' && i+1 < len(command):
+			inWord = true
+			i++
+			word.WriteByte(command[i])
+		case strings.IndexByte(" \t\n;&|<>()", c) >= 0:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// runArgs runs argv on serverID without going through string interpolation:
+// every argument reaches the remote program exactly as given.
+func (a *App) runArgs(serverID string, argv []string, stdin io.Reader) (string, error) {
+	if len(argv) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	return a.runCommand(serverID, shellJoin(argv...), stdin)
+}
+
+// validateClientArg checks a value that is passed as one argument of a
+// massa-client command. The client splits its input on whitespace and reads
+// one command per line, so such values cannot be quoted and must be rejected.
+func validateClientArg(name string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	if strings.IndexFunc(value, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("%s must not contain spaces or control characters", name)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellJoinRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	argv := []string{
+		"",
+		"plain",
+		"two words",
+		"it's",
+		`"double" \back\slash`,
+		"$(id) `id` ${HOME} $HOME",
+		"semi;colon && pipe | amp & redirect > file",
+		"line\nbreak\ttab",
+		"glob * ? [a]",
+		"~user #comment",
+		"ünïcödé",
+	}
+	cmd := exec.Command(sh, "-c", shellJoin(append([]string{"printf", `%s\0`}, argv...)...))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh failed: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	if len(got) != len(argv) {
+		t.Fatalf("got %d arguments, want %d: %q", len(got), len(argv), got)
+	}
+	for i := range argv {
+		if got[i] != argv[i] {
+			t.Errorf("argument %d: got %q, want %q", i, got[i], argv[i])
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"/root/massa_node", "/root/massa_node"},
+		{"MAIN.2.4", "MAIN.2.4"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$x", "'$x'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRunArgsQuotesEveryArgument(t *testing.T) {
+	a, fake := newFakeServerApp(t, nil)
+	argv := []string{"ls", "-l", "/opt/massa node", "$(reboot)"}
+	if _, err := a.runArgs(fakeServerID, argv, nil); err != nil {
+		t.Fatalf("runArgs: %v", err)
+	}
+	commands := fake.commands()
+	if len(commands) != 1 || commands[0].command != shellJoin(argv...) {
+		t.Fatalf("commands = %+v, want only %q", commands, shellJoin(argv...))
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la /root", []string{"ls", "-la", "/root"}},
+		{`echo 'a b' "c \"d\"" e\ f`, []string{"echo", "a b", `c "d"`, "e f"}},
+		{"a;b&&c|d>e<f", []string{"a", "b", "c", "d", "e", "f"}},
+		{"x=''", []string{"x="}},
+		{shellJoin("sh", "-c", "echo 'it''s'"), []string{"sh", "-c", "echo 'it''s'"}},
+	}
+	for _, tt := range tests {
+		if got := shellWords(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellWords(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
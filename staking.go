@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Slot timing of the Massa network: a period every 16 seconds, split across
+// 32 threads.
+const (
+	massaPeriodDuration = 16 * time.Second
+	massaThreadCount    = 32
+)
+
+// massaAmountDecimals is the number of decimals of MAS amounts.
+const massaAmountDecimals = 9
+
+// StakingCycle is an address's block production for one cycle.
+type StakingCycle struct {
+	Cycle          uint64  `json:"cycle"`
+	IsFinal        bool    `json:"isFinal"`
+	ProducedBlocks uint64  `json:"producedBlocks"`
+	MissedBlocks   uint64  `json:"missedBlocks"`
+	ActiveRolls    *uint64 `json:"activeRolls"`
+	MissRate       float64 `json:"missRate"` // Missed / (produced + missed), 0 without any draw
+}
+
+// StakingDraw is an upcoming slot an address was selected to produce a block in.
+type StakingDraw struct {
+	Slot RPCSlot   `json:"slot"`
+	ETA  time.Time `json:"eta"` // Estimated from the node's current slot
+}
+
+// StakingAddress is the staking state of one address.
+type StakingAddress struct {
+	Address              string              `json:"address"`
+	Thread               uint8               `json:"thread"`
+	Staking              bool                `json:"staking"` // The node holds the address's key and produces blocks for it
+	FinalBalance         string              `json:"finalBalance"`
+	CandidateBalance     string              `json:"candidateBalance"`
+	ActiveRolls          uint64              `json:"activeRolls"`
+	FinalRolls           uint64              `json:"finalRolls"`
+	CandidateRolls       uint64              `json:"candidateRolls"`
+	DeferredCredits      []RPCDeferredCredit `json:"deferredCredits"`
+	DeferredCreditsTotal string              `json:"deferredCreditsTotal"`
+	CurrentCycle         *StakingCycle       `json:"currentCycle"`
+	PreviousCycle        *StakingCycle       `json:"previousCycle"`
+	Cycles               []StakingCycle      `json:"cycles"` // Every cycle the node reports, oldest first
+	NextBlockDraws       []StakingDraw       `json:"nextBlockDraws"`
+	NextEndorsementDraws int                 `json:"nextEndorsementDraws"`
+}
+
+// StakingDashboard is the staking state of a node's addresses.
+type StakingDashboard struct {
+	ServerID     string           `json:"serverId"`
+	CurrentCycle uint64           `json:"currentCycle"`
+	Addresses    []StakingAddress `json:"addresses"`
+	CheckedAt    time.Time        `json:"checkedAt"`
+}
+
+// GetStakingDashboard returns balances, rolls, deferred credits, block
+// production and upcoming draws for addresses. With no addresses given it
+// covers the node's staking addresses and the client wallet's addresses.
+func (a *App) GetStakingDashboard(serverID string, addresses []string) (*StakingDashboard, error) {
+	fmt.Println("GetStakingDashboard called")
+	return a.stakingDashboard(serverID, addresses)
+}
+
+func (a *App) stakingDashboard(serverID string, addresses []string) (*StakingDashboard, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := a.GetNodeAPIStatus(serverID)
+	if err != nil {
+		return nil, err
+	}
+	staking, err := a.GetStakingAddresses(serverID)
+	if err != nil {
+		fmt.Printf("Could not get staking addresses of %s: %v\n", serverID, err)
+	}
+	isStaking := map[string]bool{}
+	for _, addr := range staking {
+		isStaking[addr] = true
+	}
+
+	if len(addresses) == 0 {
+		addresses = append(addresses, staking...)
+		walletDir := path.Join(srv.getLayout().clientDir(), "wallets")
+		output, err := a.runReadOnlyCommand(serverID, srv.asNodeUser("ls -1 "+shellQuote(walletDir)+" 2>/dev/null || true"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Fields(output) {
+			if m := walletFileAddressPattern.FindStringSubmatch(name); m != nil {
+				addresses = append(addresses, m[1])
+			}
+		}
+	}
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, addr := range addresses {
+		if addr = strings.TrimSpace(addr); addr != "" && !seen[addr] {
+			seen[addr] = true
+			unique = append(unique, addr)
+		}
+	}
+
+	infos, err := a.GetAddresses(serverID, unique)
+	if err != nil {
+		return nil, err
+	}
+	dashboard := &StakingDashboard{ServerID: serverID, CurrentCycle: status.CurrentCycle, Addresses: []StakingAddress{}, CheckedAt: time.Now()}
+	for _, info := range infos {
+		dashboard.Addresses = append(dashboard.Addresses, stakingAddress(info, status, isStaking[info.Address]))
+	}
+	return dashboard, nil
+}
+
+// stakingAddress summarises info as of the node status.
+func stakingAddress(info RPCAddressInfo, status *RPCNodeStatus, staking bool) StakingAddress {
+	s := StakingAddress{
+		Address:          info.Address,
+		Thread:           info.Thread,
+		Staking:          staking,
+		FinalBalance:     info.FinalBalance,
+		CandidateBalance: info.CandidateBalance,
+		FinalRolls:       info.FinalRollCount,
+		CandidateRolls:   info.CandidateRollCount,
+		DeferredCredits:  info.DeferredCredits,
+		Cycles:           []StakingCycle{},
+		NextBlockDraws:   []StakingDraw{},
+	}
+	if s.DeferredCredits == nil {
+		s.DeferredCredits = []RPCDeferredCredit{}
+	}
+	amounts := make([]string, 0, len(info.DeferredCredits))
+	for _, credit := range info.DeferredCredits {
+		amounts = append(amounts, credit.Amount)
+	}
+	s.DeferredCreditsTotal = sumMassaAmounts(amounts)
+
+	cycles := append([]RPCCycleInfo(nil), info.CycleInfos...)
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Cycle < cycles[j].Cycle })
+	for _, c := range cycles {
+		cycle := StakingCycle{Cycle: c.Cycle, IsFinal: c.IsFinal, ProducedBlocks: c.OkCount, MissedBlocks: c.NokCount, ActiveRolls: c.ActiveRolls}
+		if total := c.OkCount + c.NokCount; total > 0 {
+			cycle.MissRate = float64(c.NokCount) / float64(total)
+		}
+		s.Cycles = append(s.Cycles, cycle)
+	}
+	for i := range s.Cycles {
+		c := &s.Cycles[i]
+		switch {
+		case c.Cycle == status.CurrentCycle:
+			s.CurrentCycle = c
+		case c.Cycle+1 == status.CurrentCycle:
+			s.PreviousCycle = c
+		}
+		// Rolls only count once the cycle that selected them is reached.
+		if c.Cycle <= status.CurrentCycle && c.ActiveRolls != nil {
+			s.ActiveRolls = *c.ActiveRolls
+		}
+	}
+
+	for _, slot := range info.NextBlockDraws {
+		s.NextBlockDraws = append(s.NextBlockDraws, StakingDraw{Slot: slot, ETA: slotETA(slot, status)})
+	}
+	sort.Slice(s.NextBlockDraws, func(i, j int) bool { return s.NextBlockDraws[i].ETA.Before(s.NextBlockDraws[j].ETA) })
+	s.NextEndorsementDraws = len(info.NextEndorsementDraws)
+	return s
+}
+
+// slotETA estimates when slot happens from the node's next slot and clock.
+func slotETA(slot RPCSlot, status *RPCNodeStatus) time.Time {
+	now := time.UnixMilli(int64(status.CurrentTime))
+	if status.CurrentTime == 0 {
+		now = time.Now()
+	}
+	threadDuration := massaPeriodDuration / massaThreadCount
+	delta := (int64(slot.Period)-int64(status.NextSlot.Period))*int64(massaThreadCount) +
+		int64(slot.Thread) - int64(status.NextSlot.Thread)
+	return now.Add(time.Duration(delta) * threadDuration)
+}
+
+// sumMassaAmounts adds decimal MAS amounts exactly. Unparsable amounts are
+// skipped.
+func sumMassaAmounts(amounts []string) string {
+	total := new(big.Rat)
+	for _, amount := range amounts {
+		if r, ok := new(big.Rat).SetString(amount); ok {
+			total.Add(total, r)
+		}
+	}
+	s := total.FloatString(massaAmountDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
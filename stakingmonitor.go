@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// massaMaxMissRatio is the share of missed block slots in a cycle above which
+// the network deactivates an address's rolls at the end of the cycle.
+const massaMaxMissRatio = 0.7
+
+// Staking monitor defaults. Alerts fire well before the deactivation ratio so
+// there is time to fix the node while the cycle is still running.
+const (
+	stakingMonitorDefaultInterval = 60 * time.Second
+	stakingMonitorMinInterval     = 15 * time.Second
+	stakingDefaultWarnRatio       = 0.3
+	stakingDefaultCriticalRatio   = 0.5
+	// stakingAlertMinDraws keeps the first slots of a cycle from raising
+	// alerts on their own: one miss out of one draw is not yet a trend.
+	stakingAlertMinDraws = 3
+	// stakingMonitorCycles is how many cycles of history are kept per address.
+	stakingMonitorCycles = 10
+	// A warning is raised once this many checks in a row have failed, and
+	// repeated at most every stakingFailureAlertEvery.
+	stakingFailureAlertAfter = 3
+	stakingFailureAlertEvery = 30 * time.Minute
+)
+
+// Alert levels, in increasing severity.
+const (
+	stakingAlertOK       = "ok"
+	stakingAlertWarning  = "warning"
+	stakingAlertCritical = "critical"
+)
+
+var stakingAlertRank = map[string]int{stakingAlertOK: 0, stakingAlertWarning: 1, stakingAlertCritical: 2}
+
+// StakingAlert is emitted as a "staking:alert" event when an address's miss
+// ratio in a cycle crosses the warning or critical threshold, or when the node
+// repeatedly cannot be checked. The latter has no address and no counts.
+type StakingAlert struct {
+	ServerID  string    `json:"serverId"`
+	Address   string    `json:"address"`
+	Cycle     uint64    `json:"cycle"`
+	Level     string    `json:"level"` // "warning" or "critical"
+	Produced  uint64    `json:"produced"`
+	Missed    uint64    `json:"missed"`
+	MissRatio float64   `json:"missRatio"`
+	Threshold float64   `json:"threshold"` // The threshold that was crossed
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// StakingAddressRecord is the monitored production of one address.
+type StakingAddressRecord struct {
+	Address     string         `json:"address"`
+	ActiveRolls uint64         `json:"activeRolls"`
+	Cycles      []StakingCycle `json:"cycles"` // Newest first
+	Level       string         `json:"level"`  // Level of the current cycle: "ok", "warning" or "critical"
+}
+
+// StakingMonitorInfo describes the staking monitor of a server.
+type StakingMonitorInfo struct {
+	ServerID      string                 `json:"serverId"`
+	Running       bool                   `json:"running"`
+	IntervalSecs  int                    `json:"intervalSecs"`
+	WarnRatio     float64                `json:"warnRatio"`
+	CriticalRatio float64                `json:"criticalRatio"`
+	LastCheck     time.Time              `json:"lastCheck"`
+	LastError     string                 `json:"lastError,omitempty"`
+	Addresses     []StakingAddressRecord `json:"addresses"`
+	Alerts        []StakingAlert         `json:"alerts"` // Oldest first
+}
+
+// stakingCycleKey identifies a cycle of an address.
+type stakingCycleKey struct {
+	address string
+	cycle   uint64
+}
+
+// stakingMonitor polls the staking state of one server.
+type stakingMonitor struct {
+	serverID      string
+	interval      time.Duration
+	warnRatio     float64
+	criticalRatio float64
+
+	mu               sync.Mutex
+	lastCheck        time.Time
+	lastError        string
+	failures         int       // Failed checks in a row
+	lastFailureAlert time.Time // When the last failed-check alert was raised
+	currentCycle     uint64    // As of the last successful check
+	records          map[string]*StakingAddressRecord
+	levels           map[stakingCycleKey]string // Highest level alerted per address and cycle
+	alerts           []StakingAlert
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (m *stakingMonitor) close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+func (m *stakingMonitor) info() StakingMonitorInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info := StakingMonitorInfo{
+		ServerID:      m.serverID,
+		Running:       true,
+		IntervalSecs:  int(m.interval / time.Second),
+		WarnRatio:     m.warnRatio,
+		CriticalRatio: m.criticalRatio,
+		LastCheck:     m.lastCheck,
+		LastError:     m.lastError,
+		Addresses:     []StakingAddressRecord{},
+		Alerts:        append([]StakingAlert{}, m.alerts...),
+	}
+	for _, r := range m.records {
+		record := *r
+		record.Cycles = append([]StakingCycle{}, r.Cycles...)
+		info.Addresses = append(info.Addresses, record)
+	}
+	sort.Slice(info.Addresses, func(i, j int) bool { return info.Addresses[i].Address < info.Addresses[j].Address })
+	return info
+}
+
+// stakingMonitorRegistry tracks running monitors by server ID.
+type stakingMonitorRegistry struct {
+	mu       sync.Mutex
+	monitors map[string]*stakingMonitor
+}
+
+func newStakingMonitorRegistry() *stakingMonitorRegistry {
+	return &stakingMonitorRegistry{monitors: make(map[string]*stakingMonitor)}
+}
+
+func (r *stakingMonitorRegistry) get(serverID string) *stakingMonitor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.monitors[serverID]
+}
+
+// replace installs m for its server, stopping the monitor it replaces.
+func (r *stakingMonitorRegistry) replace(m *stakingMonitor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old := r.monitors[m.serverID]; old != nil {
+		old.close()
+	}
+	r.monitors[m.serverID] = m
+}
+
+// stopServer stops the monitor of serverID, or every monitor when serverID is empty.
+func (r *stakingMonitorRegistry) stopServer(serverID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, m := range r.monitors {
+		if serverID == "" || id == serverID {
+			m.close()
+			delete(r.monitors, id)
+		}
+	}
+}
+
+// StartStakingMonitor starts watching the block production of serverID's
+// staking addresses every intervalSecs seconds, raising "staking:alert"
+// events when an address's miss ratio in a cycle reaches warnRatio or
+// criticalRatio. Zero values use the defaults (60s, 0.3 and 0.5). Starting a
+// monitor again replaces the previous one and its history.
+func (a *App) StartStakingMonitor(serverID string, intervalSecs int, warnRatio float64, criticalRatio float64) (*StakingMonitorInfo, error) {
+	fmt.Println("StartStakingMonitor called")
+	if _, err := a.server(serverID); err != nil {
+		return nil, err
+	}
+	interval := time.Duration(intervalSecs) * time.Second
+	if intervalSecs == 0 {
+		interval = stakingMonitorDefaultInterval
+	}
+	if interval < stakingMonitorMinInterval {
+		return nil, fmt.Errorf("interval must be at least %s", stakingMonitorMinInterval)
+	}
+	if warnRatio == 0 {
+		warnRatio = stakingDefaultWarnRatio
+	}
+	if criticalRatio == 0 {
+		criticalRatio = stakingDefaultCriticalRatio
+	}
+	if warnRatio <= 0 || warnRatio >= criticalRatio || criticalRatio >= massaMaxMissRatio {
+		return nil, fmt.Errorf("thresholds must satisfy 0 < warning < critical < %.1f", massaMaxMissRatio)
+	}
+
+	m := &stakingMonitor{
+		serverID:      serverID,
+		interval:      interval,
+		warnRatio:     warnRatio,
+		criticalRatio: criticalRatio,
+		records:       make(map[string]*StakingAddressRecord),
+		levels:        make(map[stakingCycleKey]string),
+		stop:          make(chan struct{}),
+	}
+	a.stakingMonitors.replace(m)
+	a.checkStaking(m)
+	go a.runStakingMonitor(m)
+	info := m.info()
+	return &info, nil
+}
+
+// StopStakingMonitor stops the staking monitor of serverID.
+func (a *App) StopStakingMonitor(serverID string) {
+	fmt.Println("StopStakingMonitor called")
+	a.stakingMonitors.stopServer(serverID)
+}
+
+// GetStakingMonitor returns the state of serverID's staking monitor, with
+// Running false if none is running.
+func (a *App) GetStakingMonitor(serverID string) StakingMonitorInfo {
+	if m := a.stakingMonitors.get(serverID); m != nil {
+		return m.info()
+	}
+	return StakingMonitorInfo{ServerID: serverID, Addresses: []StakingAddressRecord{}, Alerts: []StakingAlert{}}
+}
+
+func (a *App) runStakingMonitor(m *stakingMonitor) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			a.checkStaking(m)
+		}
+	}
+}
+
+// checkStaking records the current production of every staking address and
+// raises alerts for cycles whose level went up since the last check, or when
+// the node could not be checked several times in a row.
+func (a *App) checkStaking(m *stakingMonitor) {
+	dashboard, err := a.stakingDashboard(m.serverID, nil)
+	for _, alert := range m.update(dashboard, err, time.Now()) {
+		fmt.Printf("[%s] staking %s: %s\n", m.serverID, alert.Level, alert.Message)
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "staking:alert", alert)
+		}
+	}
+}
+
+// update applies the result of a check made at now and returns the alerts it
+// raises.
+func (m *stakingMonitor) update(dashboard *StakingDashboard, err error, now time.Time) []StakingAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastCheck = now
+	if err != nil {
+		m.lastError = err.Error()
+		return m.checkFailed(err)
+	}
+	m.lastError = ""
+	m.failures = 0
+	m.currentCycle = dashboard.CurrentCycle
+
+	var raised []StakingAlert
+	monitored := map[string]bool{}
+	for _, addr := range dashboard.Addresses {
+		if !addr.Staking && addr.ActiveRolls == 0 {
+			continue
+		}
+		monitored[addr.Address] = true
+		record := &StakingAddressRecord{Address: addr.Address, ActiveRolls: addr.ActiveRolls, Cycles: []StakingCycle{}, Level: stakingAlertOK}
+		for i := len(addr.Cycles) - 1; i >= 0 && len(record.Cycles) < stakingMonitorCycles; i-- {
+			record.Cycles = append(record.Cycles, addr.Cycles[i])
+		}
+		m.records[addr.Address] = record
+
+		for _, cycle := range []*StakingCycle{addr.PreviousCycle, addr.CurrentCycle} {
+			if cycle == nil {
+				continue
+			}
+			level, threshold := m.level(*cycle)
+			if cycle == addr.CurrentCycle {
+				record.Level = level
+			}
+			key := stakingCycleKey{addr.Address, cycle.Cycle}
+			if stakingAlertRank[level] <= stakingAlertRank[m.levels[key]] {
+				continue
+			}
+			m.levels[key] = level
+			raised = append(raised, StakingAlert{
+				ServerID:  m.serverID,
+				Address:   addr.Address,
+				Cycle:     cycle.Cycle,
+				Level:     level,
+				Produced:  cycle.ProducedBlocks,
+				Missed:    cycle.MissedBlocks,
+				MissRatio: cycle.MissRate,
+				Threshold: threshold,
+				Message: fmt.Sprintf("%s missed %d of %d blocks in cycle %d (%.0f%%); rolls are deactivated above %.0f%%",
+					addr.Address, cycle.MissedBlocks, cycle.ProducedBlocks+cycle.MissedBlocks, cycle.Cycle, cycle.MissRate*100, massaMaxMissRatio*100),
+				Time: now,
+			})
+		}
+	}
+	// Addresses that stopped staking, or left the wallet, are no longer shown.
+	for address := range m.records {
+		if !monitored[address] {
+			delete(m.records, address)
+		}
+	}
+	m.alerts = append(m.alerts, raised...)
+	m.prune(dashboard.CurrentCycle)
+	return raised
+}
+
+// checkFailed counts a failed check and returns a warning once
+// stakingFailureAlertAfter checks in a row have failed, repeated at most every
+// stakingFailureAlertEvery while the node stays unreachable. A node that
+// cannot be checked may well be missing its blocks.
+func (m *stakingMonitor) checkFailed(err error) []StakingAlert {
+	m.failures++
+	if m.failures < stakingFailureAlertAfter || m.lastCheck.Sub(m.lastFailureAlert) < stakingFailureAlertEvery {
+		return nil
+	}
+	m.lastFailureAlert = m.lastCheck
+	alert := StakingAlert{
+		ServerID: m.serverID,
+		Cycle:    m.currentCycle,
+		Level:    stakingAlertWarning,
+		Message:  fmt.Sprintf("Staking could not be checked %d times in a row; blocks may be missed unnoticed: %v", m.failures, err),
+		Time:     m.lastCheck,
+	}
+	m.alerts = append(m.alerts, alert)
+	return []StakingAlert{alert}
+}
+
+// level returns the alert level of cycle and the threshold it crossed.
+func (m *stakingMonitor) level(cycle StakingCycle) (string, float64) {
+	if cycle.ProducedBlocks+cycle.MissedBlocks < stakingAlertMinDraws {
+		return stakingAlertOK, 0
+	}
+	switch {
+	case cycle.MissRate >= m.criticalRatio:
+		return stakingAlertCritical, m.criticalRatio
+	case cycle.MissRate >= m.warnRatio:
+		return stakingAlertWarning, m.warnRatio
+	}
+	return stakingAlertOK, 0
+}
+
+// prune drops alert state for cycles older than the kept history.
+func (m *stakingMonitor) prune(currentCycle uint64) {
+	if currentCycle < stakingMonitorCycles {
+		return
+	}
+	oldest := currentCycle - stakingMonitorCycles
+	alerts := m.alerts[:0]
+	for _, alert := range m.alerts {
+		if alert.Cycle >= oldest {
+			alerts = append(alerts, alert)
+		}
+	}
+	m.alerts = alerts
+	for key := range m.levels {
+		if key.cycle < oldest {
+			delete(m.levels, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestStakingMonitor() *stakingMonitor {
+	return &stakingMonitor{
+		serverID:      "test",
+		interval:      stakingMonitorDefaultInterval,
+		warnRatio:     stakingDefaultWarnRatio,
+		criticalRatio: stakingDefaultCriticalRatio,
+		records:       make(map[string]*StakingAddressRecord),
+		levels:        make(map[stakingCycleKey]string),
+		stop:          make(chan struct{}),
+	}
+}
+
+func TestStakingMonitorAlertsWhenChecksKeepFailing(t *testing.T) {
+	m := newTestStakingMonitor()
+	unreachable := errors.New("connection refused")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	check := func(err error) []StakingAlert {
+		now = now.Add(time.Minute)
+		if err != nil {
+			return m.update(nil, err, now)
+		}
+		return m.update(&StakingDashboard{CurrentCycle: 100}, nil, now)
+	}
+
+	for i := 1; i < stakingFailureAlertAfter; i++ {
+		if alerts := check(unreachable); len(alerts) != 0 {
+			t.Fatalf("failure %d raised %+v, want no alert yet", i, alerts)
+		}
+	}
+	alerts := check(unreachable)
+	if len(alerts) != 1 || alerts[0].Level != stakingAlertWarning {
+		t.Fatalf("failure %d raised %+v, want one warning", stakingFailureAlertAfter, alerts)
+	}
+	if alerts := check(unreachable); len(alerts) != 0 {
+		t.Errorf("next failure raised %+v, want it rate limited", alerts)
+	}
+	now = now.Add(stakingFailureAlertEvery)
+	if alerts := check(unreachable); len(alerts) != 1 {
+		t.Errorf("failure after %s raised %+v, want the warning repeated", stakingFailureAlertEvery, alerts)
+	}
+
+	// A successful check resets the count; failures have to add up again.
+	if alerts := check(nil); len(alerts) != 0 {
+		t.Errorf("successful check raised %+v", alerts)
+	}
+	now = now.Add(stakingFailureAlertEvery)
+	if alerts := check(unreachable); len(alerts) != 0 {
+		t.Errorf("first failure after a success raised %+v", alerts)
+	}
+}
+
+func TestStakingMonitorDropsAddressesNoLongerStaking(t *testing.T) {
+	m := newTestStakingMonitor()
+	dashboard := &StakingDashboard{CurrentCycle: 100, Addresses: []StakingAddress{
+		{Address: "AU1a", Staking: true, ActiveRolls: 1},
+		{Address: "AU1b", Staking: true, ActiveRolls: 2},
+	}}
+	m.update(dashboard, nil, time.Now())
+	if len(m.records) != 2 {
+		t.Fatalf("%d records, want 2", len(m.records))
+	}
+
+	dashboard.Addresses = dashboard.Addresses[:1]
+	m.update(dashboard, nil, time.Now())
+	if _, ok := m.records["AU1b"]; ok || len(m.records) != 1 {
+		t.Errorf("records after AU1b left = %v, want only AU1a", m.records)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"golang.org/x/crypto/ssh"
+)
+
+// Connection states reported in ServerInfo and "server:state" events.
+const (
+	connStateConnected    = "connected"
+	connStateReconnecting = "reconnecting"
+	connStateDisconnected = "disconnected"
+)
+
+const (
+	keepaliveInterval  = 15 * time.Second
+	keepaliveTimeout   = 10 * time.Second
+	keepaliveMaxMissed = 2 // Consecutive unanswered keepalives before the link is considered dead
+	reconnectMinDelay  = 1 * time.Second
+	reconnectMaxDelay  = 60 * time.Second
+	// readOnlyRetryWait is how long read-only calls wait for a reconnect before
+	// retrying once.
+	readOnlyRetryWait = 30 * time.Second
+)
+
+// errConnectionLost marks failures caused by the SSH link rather than by the
+// remote command, so callers can tell them apart and retry.
+var errConnectionLost = errors.New("lost SSH connection")
+
+// ConnectionState is emitted as a "server:state" event whenever a server's
+// connection goes down, is being re-established or comes back.
+type ConnectionState struct {
+	ServerID string    `json:"serverId"`
+	State    string    `json:"state"`
+	Attempt  int       `json:"attempt,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func (a *App) emitConnectionState(serverID string, state string, attempt int, err error) {
+	event := ConnectionState{ServerID: serverID, State: state, Attempt: attempt, Time: time.Now()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	fmt.Printf("Server %s is %s (attempt %d, error: %s)\n", serverID, state, attempt, event.Error)
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "server:state", event)
+	}
+}
+
+// superviseServer keeps srv alive until it is disconnected: it sends keepalives,
+// notices dead links and reconnects with exponential backoff using the
+// credentials the connection was opened with.
+func (a *App) superviseServer(srv *serverConn) {
+	for {
+		client, err := srv.sshClient()
+		if err != nil {
+			return
+		}
+		err = watchConnection(srv, client)
+		if srv.stopped() {
+			return
+		}
+
+		srv.markLost()
+		client.Close()
+		a.emitConnectionState(srv.id, connStateReconnecting, 0, err)
+		if !a.reconnect(srv) {
+			return
+		}
+	}
+}
+
+// watchConnection blocks until client dies or srv is stopped, returning the
+// reason the connection was considered dead.
+func watchConnection(srv *serverConn, client *ssh.Client) error {
+	closed := make(chan error, 1)
+	go func() { closed <- client.Wait() }()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-srv.stop:
+			return nil
+		case err := <-closed:
+			if err == nil {
+				err = errors.New("connection closed by server")
+			}
+			return err
+		case <-ticker.C:
+			if err := sendKeepalive(client); err != nil {
+				missed++
+				if missed >= keepaliveMaxMissed {
+					return fmt.Errorf("no keepalive reply after %d attempts: %w", missed, err)
+				}
+				continue
+			}
+			missed = 0
+		}
+	}
+}
+
+// sendKeepalive sends an OpenSSH keepalive request and waits for any reply.
+func sendKeepalive(client *ssh.Client) error {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(keepaliveTimeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+// reconnect dials srv again until it succeeds or srv is stopped. It runs in
+// the background, so the dial never prompts the user.
+func (a *App) reconnect(srv *serverConn) bool {
+	delay := reconnectMinDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-srv.stop:
+			return false
+		case <-time.After(delay):
+		}
+
+		client, err := a.dialSSH(srv.host, srv.port, srv.user, srv.auth, false)
+		if err == nil {
+			if !srv.setClient(client) {
+				return false
+			}
+			a.emitConnectionState(srv.id, connStateConnected, attempt, nil)
+			return true
+		}
+		a.emitConnectionState(srv.id, connStateReconnecting, attempt, err)
+
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+// waitForReconnect waits up to timeout for serverID to be connected again.
+func (a *App) waitForReconnect(serverID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		srv, err := a.server(serverID)
+		if err != nil {
+			return false
+		}
+		if srv.getState() == connStateConnected {
+			return true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return false
+}
+
+// runReadOnlyCommand is RunCommand for commands that are safe to repeat: if
+// the SSH link drops, it waits for the supervisor to reconnect and retries once.
+func (a *App) runReadOnlyCommand(serverID string, command string) (string, error) {
+	output, err := a.RunCommand(serverID, command)
+	if errors.Is(err, errConnectionLost) && a.waitForReconnect(serverID, readOnlyRetryWait) {
+		fmt.Printf("Retrying read-only command on %s after reconnect: %s\n", serverID, command)
+		return a.RunCommand(serverID, command)
+	}
+	return output, err
+}
+
+// isConnectionError reports whether err from a session comes from the transport
+// rather than from the remote command exiting.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exitErr *ssh.ExitError
+	return !errors.As(err, &exitErr)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tunnelDialRetries is how many times a forwarded connection retries the remote
+// dial while the SSH link is being re-established.
+const (
+	tunnelDialRetries    = 5
+	tunnelDialRetryDelay = 2 * time.Second
+)
+
+// TunnelInfo describes a local→remote port forward for the frontend.
+type TunnelInfo struct {
+	ID                string    `json:"id"`
+	ServerID          string    `json:"serverId"`
+	LocalAddr         string    `json:"localAddr"`
+	RemoteAddr        string    `json:"remoteAddr"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ActiveConnections int64     `json:"activeConnections"`
+	TotalConnections  int64     `json:"totalConnections"`
+	BytesSent         int64     `json:"bytesSent"`     // Local → remote
+	BytesReceived     int64     `json:"bytesReceived"` // Remote → local
+	LastError         string    `json:"lastError,omitempty"`
+}
+
+// tunnel is a listening local port whose connections are forwarded through the
+// SSH connection of serverID. The SSH client is looked up for every new
+// connection, so a tunnel keeps working after the link is re-established.
+type tunnel struct {
+	id         string
+	serverID   string
+	remoteAddr string
+	listener   net.Listener
+	createdAt  time.Time
+
+	// Counters are accessed atomically.
+	active   int64
+	total    int64
+	sent     int64
+	received int64
+
+	mu        sync.Mutex
+	lastError string
+	conns     map[net.Conn]struct{}
+}
+
+func (t *tunnel) setError(err error) {
+	t.mu.Lock()
+	t.lastError = err.Error()
+	t.mu.Unlock()
+}
+
+func (t *tunnel) info() TunnelInfo {
+	t.mu.Lock()
+	lastError := t.lastError
+	t.mu.Unlock()
+	return TunnelInfo{
+		ID:                t.id,
+		ServerID:          t.serverID,
+		LocalAddr:         t.listener.Addr().String(),
+		RemoteAddr:        t.remoteAddr,
+		CreatedAt:         t.createdAt,
+		ActiveConnections: atomic.LoadInt64(&t.active),
+		TotalConnections:  atomic.LoadInt64(&t.total),
+		BytesSent:         atomic.LoadInt64(&t.sent),
+		BytesReceived:     atomic.LoadInt64(&t.received),
+		LastError:         lastError,
+	}
+}
+
+func (t *tunnel) track(c net.Conn, add bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if add {
+		t.conns[c] = struct{}{}
+	} else {
+		delete(t.conns, c)
+	}
+}
+
+// close stops accepting connections and drops the ones in flight.
+func (t *tunnel) close() error {
+	err := t.listener.Close()
+	t.mu.Lock()
+	for c := range t.conns {
+		c.Close()
+	}
+	t.mu.Unlock()
+	return err
+}
+
+// tunnelRegistry tracks open tunnels by ID.
+type tunnelRegistry struct {
+	mu      sync.Mutex
+	tunnels map[string]*tunnel
+}
+
+func newTunnelRegistry() *tunnelRegistry {
+	return &tunnelRegistry{tunnels: make(map[string]*tunnel)}
+}
+
+func (r *tunnelRegistry) add(t *tunnel) {
+	r.mu.Lock()
+	r.tunnels[t.id] = t
+	r.mu.Unlock()
+}
+
+func (r *tunnelRegistry) remove(id string) *tunnel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.tunnels[id]
+	delete(r.tunnels, id)
+	return t
+}
+
+// closeServer closes every tunnel of serverID, or every tunnel when serverID is empty.
+func (r *tunnelRegistry) closeServer(serverID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range r.tunnels {
+		if serverID == "" || t.serverID == serverID {
+			t.close()
+			delete(r.tunnels, id)
+		}
+	}
+}
+
+func (r *tunnelRegistry) list() []TunnelInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]TunnelInfo, 0, len(r.tunnels))
+	for _, t := range r.tunnels {
+		infos = append(infos, t.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos
+}
+
+// OpenTunnel forwards localPort on 127.0.0.1 to remoteHost:remotePort as seen
+// from serverID. localPort 0 picks a free port; an empty remoteHost means the
+// server's loopback, e.g. OpenTunnel(id, 0, "", 33035) for the public API.
+func (a *App) OpenTunnel(serverID string, localPort int, remoteHost string, remotePort int) (TunnelInfo, error) {
+	if _, err := a.server(serverID); err != nil {
+		return TunnelInfo{}, err
+	}
+	if localPort < 0 || localPort > 65535 || remotePort < 1 || remotePort > 65535 {
+		return TunnelInfo{}, fmt.Errorf("invalid port")
+	}
+	if remoteHost == "" {
+		remoteHost = "127.0.0.1"
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)))
+	if err != nil {
+		return TunnelInfo{}, fmt.Errorf("failed to listen on local port %d: %w", localPort, err)
+	}
+	t := &tunnel{
+		id:         randomID(),
+		serverID:   serverID,
+		remoteAddr: net.JoinHostPort(remoteHost, strconv.Itoa(remotePort)),
+		listener:   listener,
+		createdAt:  time.Now(),
+		conns:      make(map[net.Conn]struct{}),
+	}
+	a.tunnels.add(t)
+	go a.acceptTunnel(t)
+
+	fmt.Printf("Opened tunnel %s: %s -> %s via %s\n", t.id, listener.Addr(), t.remoteAddr, serverID)
+	return t.info(), nil
+}
+
+// ListTunnels returns all open tunnels with their traffic counters.
+func (a *App) ListTunnels() []TunnelInfo {
+	return a.tunnels.list()
+}
+
+// CloseTunnel closes a tunnel opened with OpenTunnel.
+func (a *App) CloseTunnel(tunnelID string) error {
+	t := a.tunnels.remove(tunnelID)
+	if t == nil {
+		return fmt.Errorf("no tunnel with id %s", tunnelID)
+	}
+	fmt.Printf("Closing tunnel %s\n", tunnelID)
+	return t.close()
+}
+
+func (a *App) acceptTunnel(t *tunnel) {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			// The listener was closed by CloseTunnel or a disconnect.
+			return
+		}
+		go a.forwardTunnelConn(t, local)
+	}
+}
+
+func (a *App) forwardTunnelConn(t *tunnel, local net.Conn) {
+	defer local.Close()
+	t.track(local, true)
+	defer t.track(local, false)
+	atomic.AddInt64(&t.total, 1)
+
+	remote, err := a.dialThroughServer(t.serverID, t.remoteAddr)
+	if err != nil {
+		t.setError(err)
+		fmt.Printf("Tunnel %s: %v\n", t.id, err)
+		return
+	}
+	defer remote.Close()
+	t.track(remote, true)
+	defer t.track(remote, false)
+
+	atomic.AddInt64(&t.active, 1)
+	defer atomic.AddInt64(&t.active, -1)
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn, counter *int64) {
+		io.Copy(countingWriter{dst, counter}, src)
+		// Wake up the other direction once one side is finished.
+		dst.Close()
+		src.Close()
+		done <- struct{}{}
+	}
+	go pipe(remote, local, &t.sent)
+	go pipe(local, remote, &t.received)
+	<-done
+	<-done
+}
+
+// dialThroughServer opens a TCP connection to addr from serverID, retrying
+// for a while if the SSH link is down so a reconnect can catch up.
+func (a *App) dialThroughServer(serverID string, addr string) (net.Conn, error) {
+	var lastErr error
+	for attempt := 0; attempt < tunnelDialRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(tunnelDialRetryDelay)
+		}
+		srv, err := a.server(serverID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		client, err := srv.sshClient()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := client.Dial("tcp", addr)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = fmt.Errorf("failed to reach %s through %s: %w", addr, serverID, err)
+	}
+	return nil, lastErr
+}
+
+// countingWriter adds the number of bytes written to n, so tunnel counters
+// move while a connection is still open.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	written, err := c.w.Write(p)
+	atomic.AddInt64(c.n, int64(written))
+	return written, err
+}
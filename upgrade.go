@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultMassaVersion is installed when no version has been chosen for a server.
+const defaultMassaVersion = "MAIN.2.4"
+
+const (
+	massaReleasesURL = "https://api.github.com/repos/massalabs/massa/releases"
+
+	// upgradeHealthTimeout is how long an upgraded node gets to come up and
+	// answer on its API before the upgrade is rolled back.
+	upgradeHealthTimeout = 3 * time.Minute
+	upgradePollInterval  = 10 * time.Second
+)
+
+// massaVersionPattern matches release tags such as MAIN.2.4 or DEVN.28.3. The
+// tag ends up in a download URL and a directory name, so nothing else is allowed.
+var massaVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// MassaRelease is a published Massa release.
+type MassaRelease struct {
+	Version     string    `json:"version"` // Release tag, e.g. "MAIN.2.4"
+	Name        string    `json:"name"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Prerelease  bool      `json:"prerelease"`
+	DownloadURL string    `json:"downloadUrl"` // Linux archive, empty if the release has none
+}
+
+// validMassaVersion normalises version, treating an empty value as the default.
+func validMassaVersion(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return defaultMassaVersion, nil
+	}
+	if !massaVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid Massa version %q", version)
+	}
+	return version, nil
+}
+
+// massaArchiveName is the file name of the Linux archive of version.
+func massaArchiveName(version string) string {
+	return fmt.Sprintf("massa_%s_release_linux.tar.gz", version)
+}
+
+// massaReleaseURL is the download URL of archive, an asset of the release of
+// version.
+func massaReleaseURL(version string, archive string) string {
+	return fmt.Sprintf("https://github.com/massalabs/massa/releases/download/%s/%s", version, archive)
+}
+
+// githubAsset is a file attached to a GitHub release.
+type githubAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Digest             string `json:"digest"` // "sha256:<hex>", empty for older uploads
+}
+
+// githubRelease is the part of the GitHub release API the manager uses.
+type githubRelease struct {
+	TagName     string        `json:"tag_name"`
+	Name        string        `json:"name"`
+	PublishedAt time.Time     `json:"published_at"`
+	Prerelease  bool          `json:"prerelease"`
+	Draft       bool          `json:"draft"`
+	Assets      []githubAsset `json:"assets"`
+}
+
+// fetchGitHubJSON GETs url from the GitHub API and decodes the response into v.
+func fetchGitHubJSON(url string, v interface{}) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchGitHubRelease returns the release tagged version.
+func fetchGitHubRelease(version string) (*githubRelease, error) {
+	var release githubRelease
+	if err := fetchGitHubJSON(massaReleasesURL+"/tags/"+version, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// ListMassaReleases returns the Massa releases published on GitHub, newest first.
+func (a *App) ListMassaReleases() ([]MassaRelease, error) {
+	var payload []githubRelease
+	if err := fetchGitHubJSON(massaReleasesURL+"?per_page=30", &payload); err != nil {
+		return nil, fmt.Errorf("failed to fetch Massa releases: %w", err)
+	}
+
+	releases := []MassaRelease{}
+	for _, r := range payload {
+		if r.Draft || !massaVersionPattern.MatchString(r.TagName) {
+			continue
+		}
+		release := MassaRelease{Version: r.TagName, Name: r.Name, PublishedAt: r.PublishedAt, Prerelease: r.Prerelease}
+		for _, asset := range r.Assets {
+			if asset.Name == massaArchiveName(r.TagName) {
+				release.DownloadURL = asset.BrowserDownloadURL
+			}
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// SetMassaVersion selects the release installed on serverID by the next
+// SetupAndRunMassaComponents. It does not touch the server; use
+// UpgradeMassaNode to change the version of an existing installation.
+func (a *App) SetMassaVersion(serverID string, version string) error {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return err
+	}
+	version, err = validMassaVersion(version)
+	if err != nil {
+		return err
+	}
+	srv.setMassaVersion(version)
+	return nil
+}
+
+// GetInstalledMassaVersion returns the release installed on serverID, or an
+// empty string if it was installed before the version was recorded. The
+// version is kept in a file inside the install directory, since the binaries
+// have no reliable way to report it offline.
+func (a *App) GetInstalledMassaVersion(serverID string) (string, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return "", err
+	}
+	output, err := a.runReadOnlyCommand(serverID, "cat "+shellQuote(srv.getLayout().versionFile())+" 2>/dev/null || true")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// massaUpgradePreserved lists the files carried over from the old installation,
+// relative to the massa directory: client wallets, the node config, the
+// node's identity key and the staking keys (both the current and the legacy
+// layout).
+var massaUpgradePreserved = []string{
+	"massa-client/wallets",
+	"massa-client/wallet.dat",
+	"massa-node/config/config.toml",
+	"massa-node/config/node_privkey.key",
+	"massa-node/config/staking_wallets",
+	"massa-node/config/staking_wallet.dat",
+}
+
+// massaUpgradeStageScript downloads a release next to the current
+// installation and unpacks it once its SHA-256 matches. Arguments: staging
+// directory, download URL, archive name, expected SHA-256.
+const massaUpgradeStageScript = `set -e
+STAGING="$1"
+rm -rf "${STAGING}"
+mkdir -p "${STAGING}"
+cd "${STAGING}"
+if ! wget -q -O "$3" "$2"; then
+    echo "ERROR: failed to download $2"
+    exit 1
+fi
+ACTUAL_SHA256=$(sha256sum "$3" | cut -d' ' -f1)
+if [ "${ACTUAL_SHA256}" != "$4" ]; then
+    echo "ERROR: SHA-256 mismatch for $3: expected $4, got ${ACTUAL_SHA256}. The archive was not extracted."
+    exit 1
+fi
+echo "SHA-256 verified: ${ACTUAL_SHA256}"
+tar -xzf "$3"
+rm -f "$3"
+test -f massa/massa-node/massa-node || { echo "ERROR: archive has no massa-node binary"; exit 1; }
+test -f massa/massa-client/massa-client || { echo "ERROR: archive has no massa-client binary"; exit 1; }
+chmod +x massa/massa-node/massa-node massa/massa-client/massa-client
+echo "Staged in ${STAGING}/massa"
+`
+
+// massaUpgradeSwapScript moves the staged release into place, keeps the old
+// one as massa.previous and copies the preserved files over. Arguments:
+// install base directory, staging directory, version, verified SHA-256, data
+// directory, run-as user, preserved paths...
+const massaUpgradeSwapScript = `set -e
+BASE="$1"; STAGING="$2"; VERSION="$3"; SHA256="$4"; DATA_DIR="$5"; RUN_AS_USER="$6"; shift 6
+cd "${BASE}"
+rm -rf massa.previous
+mv massa massa.previous
+mv "${STAGING}/massa" massa
+rm -rf "${STAGING}"
+for item in "$@"; do
+    if [ -e "massa.previous/${item}" ]; then
+        mkdir -p "massa/$(dirname "${item}")"
+        rm -rf "massa/${item}"
+        cp -a "massa.previous/${item}" "massa/${item}"
+        echo "Preserved ${item}"
+    fi
+done
+if [ -n "${DATA_DIR}" ]; then
+    rm -rf massa/massa-node/storage
+    ln -s "${DATA_DIR}" massa/massa-node/storage
+    echo "Linked node storage to ${DATA_DIR}"
+fi
+printf '%s\n' "${VERSION}" > massa/VERSION
+printf '%s\n' "${SHA256}" > massa/VERSION.sha256
+printf '%s %s %s\n' "$(date -u +%Y-%m-%dT%H:%M:%SZ)" "${VERSION}" "${SHA256}" >> releases.log
+if [ -n "${RUN_AS_USER}" ]; then chown -R "${RUN_AS_USER}:" massa releases.log; fi
+`
+
+// massaUpgradeRollbackScript puts massa.previous back in place and keeps the
+// failed installation as massa.failed. Argument: install base directory. The
+// swap may have failed before a new massa directory existed, in which case
+// there is nothing to keep.
+const massaUpgradeRollbackScript = `set -e
+cd "$1"
+test -d massa.previous || { echo "ERROR: no previous installation to roll back to"; exit 1; }
+rm -rf massa.failed
+if [ -e massa ] || [ -L massa ]; then mv massa massa.failed; fi
+mv massa.previous massa
+`
+
+// UpgradeMassaNode upgrades the node on serverID to targetVersion in place.
+// The new release is downloaded next to the current one before the node is
+// stopped; wallets, config.toml and staking keys are carried over, and the old
+// installation is kept as massa.previous. If the upgraded node does not come
+// up, the previous version is restored and started again. Progress is emitted
+// as "node:progress" events with action "upgrade".
+func (a *App) UpgradeMassaNode(serverID string, targetVersion string) (*NodeControlResult, error) {
+	fmt.Println("UpgradeMassaNode called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	targetVersion, err = validMassaVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	nodePassword := srv.getNodePassword()
+	if nodePassword == "" {
+		return nil, fmt.Errorf("node password is required to upgrade Massa node; start the node once first")
+	}
+
+	layout := srv.getLayout()
+	stagingDir := fmt.Sprintf("%s/upgrade-%s", layout.InstallDir, targetVersion)
+	ctl := a.newNodeControl(serverID, "upgrade")
+
+	currentVersion, _ := a.GetInstalledMassaVersion(serverID)
+	if currentVersion == targetVersion {
+		ctl.report("check", stepSkipped, "Massa %s is already installed", targetVersion)
+		return ctl.finish(true), nil
+	}
+	if _, err := a.runArgs(serverID, []string{"test", "-f", layout.nodeDir() + "/massa-node"}, nil); err != nil {
+		ctl.report("check", stepFailed, "Massa node is not installed")
+		return ctl.finish(false), fmt.Errorf("node not installed")
+	}
+	if currentVersion == "" {
+		currentVersion = "unknown version"
+	}
+	ctl.report("check", stepDone, "Upgrading from %s to %s", currentVersion, targetVersion)
+
+	// 1. Download, verify and unpack while the old node keeps running.
+	archive, err := a.serverMassaArchive(serverID, targetVersion)
+	if err != nil {
+		ctl.report("download", stepFailed, "%v", err)
+		return ctl.finish(false), err
+	}
+	checksum, err := a.resolveMassaArchiveChecksum(targetVersion, archive)
+	if err != nil {
+		ctl.report("download", stepFailed, "%v", err)
+		return ctl.finish(false), err
+	}
+	ctl.report("download", stepRunning, "Downloading Massa %s (expecting SHA-256 %s from %s)", targetVersion, checksum.SHA256, checksum.Source)
+	stageArgs := []string{"bash", "-c", massaUpgradeStageScript, "bash", stagingDir, massaReleaseURL(targetVersion, checksum.Archive), checksum.Archive, checksum.SHA256}
+	if output, err := a.runArgs(serverID, stageArgs, nil); err != nil {
+		ctl.report("download", stepFailed, "%s", strings.TrimSpace(output))
+		a.runArgs(serverID, []string{"rm", "-rf", stagingDir}, nil)
+		return ctl.finish(false), fmt.Errorf("failed to download Massa %s: %w", targetVersion, err)
+	}
+	ctl.report("download", stepDone, "Massa %s staged in %s", targetVersion, stagingDir)
+
+	// 2. Back up the wallet, stop the node and swap the installations.
+	if backup, err := a.backupWallet(serverID, nodePassword, "upgrade"); err != nil {
+		ctl.report("backup", stepFailed, "%v", err)
+		a.runArgs(serverID, []string{"rm", "-rf", stagingDir}, nil)
+		return ctl.finish(false), fmt.Errorf("wallet backup before upgrade failed: %w", err)
+	} else if backup == nil {
+		ctl.report("backup", stepSkipped, "No wallet files found")
+	} else {
+		ctl.report("backup", stepDone, "Wallet backed up to %s", backup.Path)
+	}
+	if err := a.stopMassaNode(serverID, ctl); err != nil {
+		a.runArgs(serverID, []string{"rm", "-rf", stagingDir}, nil)
+		return ctl.finish(false), err
+	}
+	ctl.report("swap", stepRunning, "Installing Massa %s", targetVersion)
+	swapArgs := append([]string{"bash", "-c", massaUpgradeSwapScript, "bash", layout.InstallDir, stagingDir, targetVersion, checksum.SHA256,
+		layout.DataDir, layout.RunAsUser}, massaUpgradePreserved...)
+	output, err := a.runArgs(serverID, swapArgs, nil)
+	if err != nil {
+		ctl.report("swap", stepFailed, "%s", strings.TrimSpace(output))
+		return ctl.finish(false), a.rollbackMassaUpgrade(serverID, nodePassword, ctl, fmt.Errorf("failed to install Massa %s: %w", targetVersion, err))
+	}
+	ctl.report("swap", stepDone, "%s", strings.TrimSpace(output))
+
+	// 3. Start the new version and wait for it to answer on its API.
+	if err := a.startUpgradedNode(serverID, nodePassword, ctl); err != nil {
+		return ctl.finish(false), a.rollbackMassaUpgrade(serverID, nodePassword, ctl, err)
+	}
+	srv.setMassaVersion(targetVersion)
+	return ctl.finish(true), nil
+}
+
+// startUpgradedNode starts the node and waits until it is running and its API
+// reports the node's status.
+func (a *App) startUpgradedNode(serverID string, nodePassword string, ctl *nodeControl) error {
+	ctl.report("start", stepRunning, "Starting Massa node")
+	output, err := a.StartMassaNode(serverID, nodePassword)
+	if err != nil {
+		ctl.report("start", stepFailed, "%v", err)
+		return err
+	}
+	ctl.report("start", stepDone, "%s", strings.TrimSpace(output))
+
+	ctl.report("verify", stepRunning, "Waiting up to %s for the node API", upgradeHealthTimeout)
+	deadline := time.Now().Add(upgradeHealthTimeout)
+	for {
+		time.Sleep(upgradePollInterval)
+		pids, pidErr := a.massaNodePIDs(serverID)
+		if pidErr == nil && len(pids) == 0 {
+			ctl.report("verify", stepFailed, "Massa node exited after starting")
+			return fmt.Errorf("massa node exited after starting")
+		}
+		status, err := a.GetNodeAPIStatus(serverID)
+		if err == nil {
+			ctl.report("verify", stepDone, "Node %s is up (version %s)", status.NodeID, status.Version)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			ctl.report("verify", stepFailed, "Node API not reachable after %s: %v", upgradeHealthTimeout, err)
+			return fmt.Errorf("node API not reachable after upgrade: %w", err)
+		}
+	}
+}
+
+// rollbackMassaUpgrade restores massa.previous and starts it. It returns cause,
+// annotated with the outcome of the rollback.
+func (a *App) rollbackMassaUpgrade(serverID string, nodePassword string, ctl *nodeControl, cause error) error {
+	ctl.report("rollback", stepRunning, "Restoring the previous version")
+	if err := a.stopMassaNode(serverID, ctl); err != nil {
+		ctl.report("rollback", stepFailed, "Could not stop the upgraded node: %v", err)
+		return fmt.Errorf("%w; rollback failed: %v", cause, err)
+	}
+	srv, err := a.server(serverID)
+	if err != nil {
+		return fmt.Errorf("%w; rollback failed: %v", cause, err)
+	}
+	if output, err := a.runArgs(serverID, []string{"bash", "-c", massaUpgradeRollbackScript, "bash", srv.getLayout().InstallDir}, nil); err != nil {
+		ctl.report("rollback", stepFailed, "%s", strings.TrimSpace(output))
+		return fmt.Errorf("%w; rollback failed: %v", cause, err)
+	}
+	if err := a.startUpgradedNode(serverID, nodePassword, ctl); err != nil {
+		ctl.report("rollback", stepFailed, "Previous version did not come back up: %v", err)
+		return fmt.Errorf("%w; previous version did not come back up: %v", cause, err)
+	}
+	ctl.report("rollback", stepDone, "Previous version restored and running")
+	return fmt.Errorf("%w; rolled back to the previous version", cause)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	walletBackupFileVersion = 1
+	walletBackupSuffix      = ".massa-wallet.json"
+	walletBackupVerifier    = "massa-node-manager-wallet"
+)
+
+// massaWalletFiles are the files a wallet backup holds, relative to the
+// install's massa directory: client wallets, the node's identity key and the
+// staking keys, in both the current and the legacy layout.
+var massaWalletFiles = []string{
+	"massa-client/wallets",
+	"massa-client/wallet.dat",
+	"massa-node/config/node_privkey.key",
+	"massa-node/config/staking_wallets",
+	"massa-node/config/staking_wallet.dat",
+}
+
+// walletFileAddressPattern extracts the address from wallet file names such
+// as wallet_AU12....yaml.
+var walletFileAddressPattern = regexp.MustCompile(`^wallet_(A[US][1-9A-HJ-NP-Za-km-z]+)\.yaml$`)
+
+// WalletBackupInfo describes a wallet backup. It is stored unencrypted next
+// to the encrypted files so backups can be listed without the passphrase.
+type WalletBackupInfo struct {
+	Path         string    `json:"path"`
+	ServerHost   string    `json:"serverHost"`
+	InstallDir   string    `json:"installDir"`
+	MassaVersion string    `json:"massaVersion"`
+	Addresses    []string  `json:"addresses"`
+	Files        []string  `json:"files"`
+	Reason       string    `json:"reason"` // "manual", or the operation that triggered an automatic backup
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// walletBackupFile is the on-disk format of a wallet backup. Data is a gzipped
+// tar of the wallet files, sealed with a key derived from the passphrase.
+type walletBackupFile struct {
+	Version  int              `json:"version"`
+	Info     WalletBackupInfo `json:"info"`
+	Salt     string           `json:"salt"`
+	Verifier string           `json:"verifier"`
+	Data     string           `json:"data"`
+}
+
+// walletBackupDir is where wallet backups are kept on this machine.
+func walletBackupDir() string {
+	return filepath.Join(appConfigDir(), "wallet-backups")
+}
+
+// BackupWallet downloads the wallet files of serverID into an
+// encrypted archive in the manager's wallet-backups directory. An empty
+// passphrase uses the node password.
+func (a *App) BackupWallet(serverID string, passphrase string) (*WalletBackupInfo, error) {
+	fmt.Println("BackupWallet called")
+	info, err := a.backupWallet(serverID, passphrase, "manual")
+	if err == nil && info == nil {
+		return nil, fmt.Errorf("no wallet files found on the server")
+	}
+	return info, err
+}
+
+// ListWalletBackups returns the backups of all servers, newest first.
+func (a *App) ListWalletBackups() ([]WalletBackupInfo, error) {
+	entries, err := os.ReadDir(walletBackupDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return []WalletBackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []WalletBackupInfo{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), walletBackupSuffix) {
+			continue
+		}
+		file, err := readWalletBackup(filepath.Join(walletBackupDir(), entry.Name()))
+		if err != nil {
+			fmt.Printf("Skipping wallet backup %s: %v\n", entry.Name(), err)
+			continue
+		}
+		backups = append(backups, file.Info)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// RestoreWallet writes the files of a backup back to serverID. The node must
+// be stopped. The server's current wallet files are backed up first, so a
+// restore can be undone. An empty passphrase uses the node password.
+func (a *App) RestoreWallet(serverID string, backupPath string, passphrase string) (*WalletBackupInfo, error) {
+	fmt.Println("RestoreWallet called")
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		passphrase = srv.getNodePassword()
+	}
+	file, err := readWalletBackup(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := file.open(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if pids, err := a.massaNodePIDs(serverID); err != nil {
+		return nil, err
+	} else if len(pids) > 0 {
+		return nil, fmt.Errorf("stop the Massa node before restoring its wallet")
+	}
+
+	if _, err := a.backupWallet(serverID, passphrase, "restore"); err != nil {
+		return nil, fmt.Errorf("failed to back up the current wallet before restoring: %w", err)
+	}
+
+	// The files belong to the node user and the directories may not be
+	// writable by the SSH user, so they are unpacked as root and handed back
+	// to the node user afterwards.
+	var archive bytes.Buffer
+	if err := writeWalletTar(&archive, files); err != nil {
+		return nil, err
+	}
+	massaDir := srv.getLayout().massaDir()
+	cmd := srv.asRoot(shellJoin("sh", "-c", `mkdir -p "$1" && cd "$1" && umask 077 && tar -xf - --no-same-owner`, "sh", massaDir))
+	if err := a.runCommandStream(serverID, cmd, &archive, io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to write the wallet files to %s: %w", massaDir, err)
+	}
+	if err := a.chownToNodeUser(serverID); err != nil {
+		return nil, err
+	}
+	return &file.Info, nil
+}
+
+// backupWallet writes a backup of serverID's wallet files and returns its
+// info, or nil if the server has no wallet files.
+func (a *App) backupWallet(serverID string, passphrase string, reason string) (*WalletBackupInfo, error) {
+	srv, err := a.server(serverID)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		passphrase = srv.getNodePassword()
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("a passphrase or the node password is required to encrypt the wallet backup")
+	}
+
+	files, err := a.downloadWalletFiles(srv)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	info := WalletBackupInfo{
+		ServerHost: srv.host,
+		InstallDir: srv.getLayout().InstallDir,
+		Addresses:  []string{},
+		Files:      []string{},
+		Reason:     reason,
+		CreatedAt:  time.Now().UTC(),
+	}
+	info.MassaVersion, _ = a.GetInstalledMassaVersion(serverID)
+	seen := map[string]bool{}
+	for name := range files {
+		info.Files = append(info.Files, name)
+		if m := walletFileAddressPattern.FindStringSubmatch(path.Base(name)); m != nil && !seen[m[1]] {
+			seen[m[1]] = true
+			info.Addresses = append(info.Addresses, m[1])
+		}
+	}
+	sort.Strings(info.Files)
+	sort.Strings(info.Addresses)
+
+	archive, err := packWalletFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	file := walletBackupFile{Version: walletBackupFileVersion, Salt: base64.StdEncoding.EncodeToString(salt)}
+	if file.Verifier, err = sealString(key, []byte(walletBackupVerifier)); err != nil {
+		return nil, err
+	}
+	if file.Data, err = sealString(key, archive); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(walletBackupDir(), 0700); err != nil {
+		return nil, err
+	}
+	host := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(srv.host)
+	info.Path = filepath.Join(walletBackupDir(), fmt.Sprintf("%s-%s-%s%s", host, info.CreatedAt.Format("20060102-150405"), reason, walletBackupSuffix))
+	file.Info = info
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(info.Path, raw, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write wallet backup: %w", err)
+	}
+	fmt.Printf("Backed up %d wallet file(s) of %s to %s\n", len(info.Files), srv.host, info.Path)
+	return &info, nil
+}
+
+// walletTarScript writes a tar of the wallet files that exist under the
+// massa directory ($1) to stdout. Arguments after $1 are the massaWalletFiles.
+const walletTarScript = `cd "$1" || exit 1
+shift
+count=$#
+for name; do
+    [ -e "${name}" ] && set -- "$@" "${name}"
+done
+shift "${count}"
+[ "$#" -eq 0 ] && exit 0
+exec tar -cf - -- "$@"
+`
+
+// downloadWalletFiles reads the massaWalletFiles present on the server,
+// keyed by their path relative to the massa directory. They are read as root
+// and streamed as a tar, so neither the SSH user's permissions nor an sftp
+// subsystem matter, and their contents never reach the log.
+func (a *App) downloadWalletFiles(srv *serverConn) (map[string][]byte, error) {
+	massaDir := srv.getLayout().massaDir()
+	argv := append([]string{"sh", "-c", walletTarScript, "sh", massaDir}, massaWalletFiles...)
+	var archive bytes.Buffer
+	if err := a.runCommandStream(srv.id, srv.asRoot(shellJoin(argv...)), nil, &archive); err != nil {
+		return nil, fmt.Errorf("failed to read the wallet files in %s: %w", massaDir, err)
+	}
+	if archive.Len() == 0 {
+		return map[string][]byte{}, nil
+	}
+	return readWalletTar(&archive)
+}
+
+// readWalletBackup loads a backup file without decrypting it.
+func readWalletBackup(name string) (*walletBackupFile, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet backup: %w", err)
+	}
+	var file walletBackupFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid wallet backup: %w", err)
+	}
+	if file.Version != walletBackupFileVersion {
+		return nil, fmt.Errorf("unsupported wallet backup version %d", file.Version)
+	}
+	file.Info.Path = name
+	return &file, nil
+}
+
+// open decrypts the backup and returns its files.
+func (f *walletBackupFile) open(passphrase string) (map[string][]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(f.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt in wallet backup: %w", err)
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if plain, err := openString(key, f.Verifier); err != nil || string(plain) != walletBackupVerifier {
+		return nil, fmt.Errorf("wrong passphrase for wallet backup")
+	}
+	archive, err := openString(key, f.Data)
+	if err != nil {
+		return nil, err
+	}
+	return unpackWalletFiles(archive)
+}
+
+// packWalletFiles returns files as a gzipped tar.
+func packWalletFiles(files map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := writeWalletTar(gz, files); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackWalletFiles reverses packWalletFiles.
+func unpackWalletFiles(archive []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet archive: %w", err)
+	}
+	return readWalletTar(gz)
+}
+
+// writeWalletTar writes files to w as a tar, in name order.
+func writeWalletTar(w io.Writer, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// readWalletTar returns the regular files of a tar read from r. Entries
+// outside the massaWalletFiles locations are rejected.
+func readWalletTar(r io.Reader) (map[string][]byte, error) {
+	tr := tar.NewReader(r)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid wallet archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		allowed := false
+		for _, prefix := range massaWalletFiles {
+			if name == prefix || strings.HasPrefix(name, prefix+"/") {
+				allowed = true
+			}
+		}
+		if !allowed || strings.Contains(name, "..") {
+			return nil, fmt.Errorf("unexpected file %q in wallet archive", header.Name)
+		}
+		if files[name], err = io.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("invalid wallet archive: %w", err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalletBackupAndRestoreStreamTar(t *testing.T) {
+	wallet := map[string][]byte{
+		"massa-client/wallets/wallet_AU12abc.yaml": []byte("encrypted wallet contents"),
+		"massa-node/config/node_privkey.key":       []byte("node private key contents"),
+	}
+	var archive bytes.Buffer
+	if err := writeWalletTar(&archive, wallet); err != nil {
+		t.Fatal(err)
+	}
+	a, fake := newFakeServerApp(t, func(command string) (string, uint32, bool) {
+		if strings.Contains(command, "tar -cf -") {
+			return archive.String(), 0, true
+		}
+		return "", 0, false
+	})
+	srv, _ := a.server(fakeServerID)
+	srv.setNodePassword(testNodePassword)
+
+	var info *WalletBackupInfo
+	stdout := captureStdout(t, func() {
+		var err error
+		if info, err = a.BackupWallet(fakeServerID, ""); err != nil {
+			t.Fatalf("backup: %v", err)
+		}
+		if _, err := a.RestoreWallet(fakeServerID, info.Path, ""); err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+	})
+
+	if want := []string{"massa-client/wallets/wallet_AU12abc.yaml", "massa-node/config/node_privkey.key"}; !reflect.DeepEqual(info.Files, want) {
+		t.Errorf("backed up files = %q, want %q", info.Files, want)
+	}
+	if want := []string{"AU12abc"}; !reflect.DeepEqual(info.Addresses, want) {
+		t.Errorf("addresses = %q, want %q", info.Addresses, want)
+	}
+	for _, content := range wallet {
+		if strings.Contains(stdout, string(content)) {
+			t.Errorf("log output contains wallet file contents:\n%s", stdout)
+		}
+	}
+
+	var restored map[string][]byte
+	for _, c := range fake.commands() {
+		if strings.Contains(c.command, "tar -xf -") {
+			files, err := readWalletTar(strings.NewReader(c.stdin))
+			if err != nil {
+				t.Fatalf("restore sent an invalid tar: %v", err)
+			}
+			restored = files
+		}
+	}
+	if !reflect.DeepEqual(restored, wallet) {
+		t.Errorf("restored files = %q, want %q", restored, wallet)
+	}
+}
+
+func TestReadWalletTarRejectsOtherFiles(t *testing.T) {
+	var archive bytes.Buffer
+	if err := writeWalletTar(&archive, map[string][]byte{"massa-node/config/config.toml": []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readWalletTar(&archive); err == nil {
+		t.Error("readWalletTar accepted a file outside the wallet locations")
+	}
+}